@@ -0,0 +1,220 @@
+package fileserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// The default maximum number of entries of a dir archive.
+	defaultMaxArchiveEntries = 10000
+	// The default maximum total size, in bytes, of the files of a dir archive, 1gb.
+	defaultMaxArchiveSize = 1 << 30
+)
+
+// An entry of a dir archive.
+type archiveEntry struct {
+	// The slash-separated path of the entry in the archive, relative to the archived dir.
+	name string
+	// The slash-separated path of the entry in the [fs.FS].
+	path string
+	info fs.FileInfo
+}
+
+// Serves an archive of the dir name, in the format of the 'archive' query parameter, either 'zip' or
+// 'tar.gz'. The archive is streamed from the [fs.FS] and stops if the request is cancelled.
+//
+// Hidden and denied files are skipped. The archive is rejected with [ErrArchiveTooLarge] if it exceeds
+// the limits of the server, which are checked before anything is written.
+func (s *Server) serveArchive(w http.ResponseWriter, r *http.Request, name, format string) {
+	var ext, contentType string
+	switch format {
+	case "zip":
+		ext, contentType = ".zip", "application/zip"
+	case "tar.gz":
+		ext, contentType = ".tar.gz", "application/gzip"
+	default:
+		s.errHandler(w, r, &Error{StatusCode: http.StatusBadRequest, Op: "archive", Path: name, Err: fmt.Errorf("%w: archive: unsupported format %q", ErrInvalidQuery, format)})
+		return
+	}
+
+	entries, err := s.archiveEntries(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			s.errHandler(w, r, &Error{StatusCode: http.StatusForbidden, Op: "archive", Path: name, Err: err})
+		} else {
+			s.errHandler(w, r, fsError("archive", name, err))
+		}
+		return
+	}
+
+	filename := "archive"
+	if name != "." {
+		filename = path.Base(name)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename + ext}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if format == "zip" {
+		err = s.writeZip(r.Context(), w, entries)
+	} else {
+		err = s.writeTarGz(r.Context(), w, entries)
+	}
+	if err != nil {
+		// The status was already sent, so the response can only be aborted for the client to notice
+		// the archive is incomplete.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// Walks the dir name, returning the entries of its archive in order.
+func (s *Server) archiveEntries(ctx context.Context, name string) ([]archiveEntry, error) {
+	var (
+		entries []archiveEntry
+		size    int64
+	)
+	err := fs.WalkDir(s.fs, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == name {
+			return nil
+		}
+		if s.hidden(p) {
+			return skipEntry(d)
+		}
+		// Only regular files and dirs are archived.
+		if !d.Type().IsRegular() && !d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entryName := p
+		if name != "." {
+			entryName = strings.TrimPrefix(p, name+"/")
+		}
+		entries = append(entries, archiveEntry{name: entryName, path: p, info: info})
+		if !d.IsDir() {
+			size += info.Size()
+		}
+
+		if len(entries) > s.maxArchiveEntries {
+			return fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, s.maxArchiveEntries)
+		}
+		if size > s.maxArchiveSize {
+			return fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, s.maxArchiveSize)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Writes a zip archive of entries to w.
+func (s *Server) writeZip(ctx context.Context, w io.Writer, entries []archiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		header, err := zip.FileInfoHeader(entry.info)
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if !entry.info.IsDir() {
+			if err := s.copyArchiveFile(ctx, fw, entry); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// Writes a gzip compressed tar archive of entries to w.
+func (s *Server) writeTarGz(ctx context.Context, w io.Writer, entries []archiveEntry) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	for _, entry := range entries {
+		header, err := tar.FileInfoHeader(entry.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !entry.info.IsDir() {
+			if err := s.copyArchiveFile(ctx, tw, entry); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
+
+// Copies the contents of the file of entry to w, stopping if ctx is cancelled.
+func (s *Server) copyArchiveFile(ctx context.Context, w io.Writer, entry archiveEntry) error {
+	f, err := s.fs.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The archive header has the size from the walk, so the copy must match it even if the file changed.
+	n, err := io.Copy(w, io.LimitReader(&contextReader{ctx: ctx, r: f}, entry.info.Size()))
+	if err != nil {
+		return err
+	}
+	if n != entry.info.Size() {
+		return fmt.Errorf("file %q changed while archiving", entry.path)
+	}
+	return nil
+}
+
+// contextReader stops reading once its context is cancelled, such as when the client disconnects.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
@@ -0,0 +1,143 @@
+package fileserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+var archiveFS = fstest.MapFS{
+	"docs/a.txt":        {Data: []byte("a")},
+	"docs/nested/b.txt": {Data: []byte("bb")},
+	"docs/.env":         {Data: []byte("SECRET=1")},
+	"docs/secret.key":   {Data: []byte("key")},
+	"docs/index.html":   {Data: []byte("<!doctype html>")},
+}
+
+func TestServerArchive(t *testing.T) {
+	expected := []string{"a.txt", "index.html", "nested/", "nested/b.txt"}
+
+	testCases := []struct {
+		name   string
+		opts   []ServerOptFn
+		target string
+		status int
+	}{
+		{
+			name:   "disabled",
+			target: "/docs/?archive=zip",
+			status: http.StatusOK, // Serves the index file
+		},
+		{
+			name:   "zip",
+			opts:   []ServerOptFn{WithArchives(true), WithDeny("*.key")},
+			target: "/docs?archive=zip",
+			status: http.StatusOK,
+		},
+		{
+			name:   "tar.gz",
+			opts:   []ServerOptFn{WithArchives(true), WithDeny("*.key")},
+			target: "/docs/?archive=tar.gz",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unsupported format",
+			opts:   []ServerOptFn{WithArchives(true)},
+			target: "/docs/?archive=rar",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too many entries",
+			opts:   []ServerOptFn{WithArchives(true), WithArchiveLimits(2, 1<<20)},
+			target: "/docs/?archive=zip",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "too large",
+			opts:   []ServerOptFn{WithArchives(true), WithArchiveLimits(100, 2)},
+			target: "/docs/?archive=zip",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(archiveFS, tt.opts...)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			http.StripPrefix("/", h).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+
+			var names []string
+			switch w.Header().Get("Content-Type") {
+			case "application/zip":
+				zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+				if err != nil {
+					t.Fatalf("unexpected error reading zip: %s", err)
+				}
+				for _, f := range zr.File {
+					names = append(names, f.Name)
+				}
+			case "application/gzip":
+				gzr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("unexpected error reading gzip: %s", err)
+				}
+				tr := tar.NewReader(gzr)
+				for {
+					header, err := tr.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatalf("unexpected error reading tar: %s", err)
+					}
+					names = append(names, header.Name)
+				}
+			default:
+				return
+			}
+
+			if !slices.Equal(names, expected) {
+				t.Errorf("expected entries to be %v but got %v", expected, names)
+			}
+			disposition := `attachment; filename=docs.` + tt.name
+			if cd := w.Header().Get("Content-Disposition"); cd != disposition {
+				t.Errorf("expected Content-Disposition to be %q but got %q", disposition, cd)
+			}
+		})
+	}
+}
+
+func TestServerArchiveCancelled(t *testing.T) {
+	h := New(archiveFS, WithArchives(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/docs/?archive=zip", nil).WithContext(ctx)
+	http.StripPrefix("/", h).ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status to be %d but got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	err := h.copyArchiveFile(ctx, io.Discard, archiveEntry{path: "docs/a.txt", info: fakeFileInfo{size: 1}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error to be %v but got %v", context.Canceled, err)
+	}
+}
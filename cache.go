@@ -0,0 +1,196 @@
+package fileserver
+
+import (
+	"container/list"
+	"io/fs"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Default memory budget of the compression cache, 32mb.
+const defaultCompressionCacheSize = 32 << 20
+
+// compressionCache is a LRU cache of compressed file contents bounded by the total size of
+// the cached data.
+//
+// Concurrent misses for the same key are collapsed into a single call of the function that
+// produces the value.
+type compressionCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	ll      *list.List
+	items   map[string]*list.Element
+	group   singleflight.Group
+}
+
+type compressionCacheEntry struct {
+	key  string
+	data []byte
+}
+
+// Creates a new [compressionCache] holding at most maxSize bytes.
+func newCompressionCache(maxSize int64) *compressionCache {
+	return &compressionCache{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// Returns the cached value for key, marking it as the most recently used.
+func (c *compressionCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*compressionCacheEntry).data, true
+}
+
+// Adds data to the cache, evicting the least recently used entries until it fits in the
+// memory budget. Values larger than the budget are not cached.
+func (c *compressionCache) add(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	for c.size+size > c.maxSize {
+		c.removeElement(c.ll.Back())
+	}
+	c.items[key] = c.ll.PushFront(&compressionCacheEntry{key: key, data: data})
+	c.size += size
+}
+
+func (c *compressionCache) removeElement(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*compressionCacheEntry)
+	delete(c.items, entry.key)
+	c.size -= int64(len(entry.data))
+}
+
+// Returns the cached value for key. On a miss, fn is called to produce the value, which is then
+// cached. Concurrent callers for the same key wait for and share the result of a single call.
+func (c *compressionCache) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	if data, ok := c.get(key); ok {
+		return data, nil
+	}
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		// Another caller may have populated the cache after the previous check.
+		if data, ok := c.get(key); ok {
+			return data, nil
+		}
+		data, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		c.add(key, data)
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// Default maximum number of entries of the ETag cache.
+const defaultETagCacheSize = 10000
+
+// etagCache is a LRU cache of file ETags and digests bounded by the number of entries.
+//
+// Entries are keyed by the file path and hold the size and modification time of the file when the
+// hashes were calculated. An entry is considered stale, and the hashes calculated again, as soon as
+// either of them changes.
+type etagCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type etagCacheEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	hashes  fileHashes
+}
+
+// Creates a new [etagCache] holding at most maxEntries ETags.
+func newETagCache(maxEntries int) *etagCache {
+	return &etagCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Returns the cached hashes of the file, if they're still fresh. The returned digests must not be modified.
+func (c *etagCache) get(name string, stat fs.FileInfo) (fileHashes, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[name]
+	if !ok {
+		return fileHashes{}, false
+	}
+	entry := elem.Value.(*etagCacheEntry)
+	if entry.size != stat.Size() || !entry.modTime.Equal(stat.ModTime()) {
+		c.removeElement(elem)
+		return fileHashes{}, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.hashes, true
+}
+
+// Caches the hashes of the file, evicting the least recently used entry if the cache is full.
+func (c *etagCache) add(name string, stat fs.FileInfo, hashes fileHashes) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[name]; ok {
+		c.removeElement(elem)
+	}
+	for c.ll.Len() >= c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+	c.items[name] = c.ll.PushFront(&etagCacheEntry{
+		name:    name,
+		size:    stat.Size(),
+		modTime: stat.ModTime(),
+		hashes:  hashes,
+	})
+}
+
+// Removes the ETags of the given files from the cache. If no names are provided, the whole
+// cache is cleared.
+func (c *etagCache) invalidate(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(names) == 0 {
+		c.ll.Init()
+		c.items = make(map[string]*list.Element)
+		return
+	}
+	for _, name := range names {
+		if elem, ok := c.items[name]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *etagCache) removeElement(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*etagCacheEntry)
+	delete(c.items, entry.name)
+}
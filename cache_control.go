@@ -1,12 +1,61 @@
 package fileserver
 
 import (
+	"fmt"
+	"io/fs"
 	"net/http"
+	"path"
 	"slices"
+	"strings"
+	"time"
 )
 
 type CacheControlFunc func(r *http.Request) string
 
+// FileMeta describes the file being served, as resolved by the server.
+type FileMeta struct {
+	// The slash-separated path of the served file. For SPA fallback responses, this is the fallback file.
+	Path string
+	// The info of the served file.
+	Info fs.FileInfo
+	// The value of the Content-Type header, detected from the uncompressed content.
+	ContentType string
+	// Whether the file is served as the fallback of a SPA, because the requested path wasn't found.
+	Fallback bool
+}
+
+// CacheHeaders holds the caching headers of a response. Empty values aren't set.
+type CacheHeaders struct {
+	// The value of the 'Cache-Control' header.
+	CacheControl string
+	// The value of the 'Expires' header.
+	Expires time.Time
+	// The value of the 'CDN-Cache-Control' header (RFC 9213), which targets CDNs.
+	CDNCacheControl string
+	// The value of the 'Surrogate-Control' header, which targets reverse proxies such as Fastly or Varnish.
+	SurrogateControl string
+}
+
+// Sets the non-empty caching headers of h to w.
+func (h CacheHeaders) set(w http.ResponseWriter) {
+	if h.CacheControl != "" {
+		w.Header().Set("Cache-Control", h.CacheControl)
+	}
+	if !h.Expires.IsZero() {
+		w.Header().Set("Expires", h.Expires.UTC().Format(http.TimeFormat))
+	}
+	if h.CDNCacheControl != "" {
+		w.Header().Set("CDN-Cache-Control", h.CDNCacheControl)
+	}
+	if h.SurrogateControl != "" {
+		w.Header().Set("Surrogate-Control", h.SurrogateControl)
+	}
+}
+
+// CacheHeadersFunc returns the caching headers of a response from the metadata of the served file.
+// See [WithCacheHeadersFunc].
+type CacheHeadersFunc func(r *http.Request, file FileMeta) CacheHeaders
+
 // Sets the value of 'no-cache' to the 'Cache-Control' header for all files.
 func NoCache(_ *http.Request) string {
 	return "no-cache"
@@ -15,12 +64,170 @@ func NoCache(_ *http.Request) string {
 // Sets the value of 'public, max-age=31536000, immutable' to the 'Cache-Control' header for all files. You can provide
 // a ignore list that won't be treated as permanent.
 //
-// This option is suitable for assets bundled with Vite, Webpack, etc.
+// This option is suitable for assets bundled with Vite, Webpack, etc. To only cache fingerprinted files
+// forever, use [CachePolicy] with [FingerprintRule] instead.
 func Immutable(ignore ...string) CacheControlFunc {
 	return func(r *http.Request) string {
 		if slices.Contains(ignore, r.URL.Path) {
 			return "no-cache"
 		}
-		return "public, max-age=31536000, immutable"
+		return CacheImmutable
+	}
+}
+
+// Common 'Cache-Control' directives.
+const (
+	// Caches the file for a year without revalidation. Only suitable for files that never change
+	// under the same name, such as fingerprinted assets.
+	CacheImmutable = "public, max-age=31536000, immutable"
+	// Allows caching the file, but requires revalidation with the server before each use.
+	CacheRevalidate = "no-cache"
+	// Disables caching of the file.
+	CacheNoStore = "no-store"
+)
+
+// CacheRule maps the files it matches to a 'Cache-Control' value. See [CachePolicy].
+type CacheRule struct {
+	// Reports whether the rule applies to name, the slash-separated path of the requested file.
+	Match func(name string) bool
+	// The value of the 'Cache-Control' header of matched files.
+	Directives string
+}
+
+// Creates a [CacheControlFunc] from rules, which are checked in order. The directives of the first
+// matching rule are used, and files not matched by any rule get the fallback directives.
+//
+// For example, to cache fingerprinted assets forever while revalidating everything else:
+//
+//	CachePolicy(CacheRevalidate,
+//		FingerprintRule(CacheImmutable),
+//		ExtRule("public, max-age=86400", ".woff2", ".png"),
+//	)
+func CachePolicy(fallback string, rules ...CacheRule) CacheControlFunc {
+	return func(r *http.Request) string {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		for _, rule := range rules {
+			if rule.Match(name) {
+				return rule.Directives
+			}
+		}
+		return fallback
+	}
+}
+
+// Creates a [CacheRule] that matches files by a glob pattern, with the syntax of [path.Match]. The
+// pattern is matched against both the path of the file and its base name, so 'robots.txt' matches
+// at any depth while 'assets/*' only matches files in the assets dir.
+//
+// Panics if the pattern is malformed.
+func GlobRule(pattern, directives string) CacheRule {
+	mustValidGlob(pattern)
+	return CacheRule{
+		Match: func(name string) bool {
+			return matchGlob(pattern, name)
+		},
+		Directives: directives,
+	}
+}
+
+// Reports whether the glob pattern matches either the slash-separated path name or its base name.
+func matchGlob(pattern, name string) bool {
+	if ok, _ := path.Match(pattern, name); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, path.Base(name))
+	return ok
+}
+
+// Panics if the glob pattern is malformed.
+func mustValidGlob(pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("fileserver: invalid glob %q: %s", pattern, err))
+	}
+}
+
+// Creates a [CacheRule] that matches files by extension, such as '.js' or 'js'. Extensions are
+// case-insensitive.
+func ExtRule(directives string, exts ...string) CacheRule {
+	normalized := make([]string, len(exts))
+	for i, ext := range exts {
+		normalized[i] = "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+	}
+	return CacheRule{
+		Match: func(name string) bool {
+			return slices.Contains(normalized, strings.ToLower(path.Ext(name)))
+		},
+		Directives: directives,
+	}
+}
+
+// Creates a [CacheRule] that matches fingerprinted files, as reported by [IsFingerprinted].
+func FingerprintRule(directives string) CacheRule {
+	return CacheRule{Match: IsFingerprinted, Directives: directives}
+}
+
+// Reports whether the base name of name contains a content hash, which means that its contents never
+// change under the same name. Recognizes the naming of most bundlers:
+//
+//   - A dot-separated hash, such as 'app.3f9a1c.js' or 'main.3f9a1c2b.chunk.js' (Webpack, Parcel);
+//   - A dash-separated hash of 8 characters, such as 'index-BkZ3a9xQ.js' (Vite, Rollup, esbuild).
+func IsFingerprinted(name string) bool {
+	base := path.Base(name)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+
+	// The first segment is the name of the file, so it's never a hash.
+	segments := strings.Split(stem, ".")
+	for _, segment := range segments[1:] {
+		if isHexHash(segment) || (len(segment) >= 8 && isBase64Hash(segment)) {
+			return true
+		}
+	}
+
+	// Vite hashes are base64url encoded, so they may contain dashes themselves.
+	first := segments[0]
+	if len(first) > 9 && first[len(first)-9] == '-' {
+		return isBase64Hash(first[len(first)-8:])
+	}
+	return false
+}
+
+// Reports whether s looks like a hex encoded hash of at least 6 characters. Hashes must have both a
+// letter and a digit, so dates such as '20240101' and words such as 'facade' aren't mistaken for them.
+func isHexHash(s string) bool {
+	if len(s) < 6 {
+		return false
+	}
+	var digit, letter bool
+	for _, c := range s {
+		switch {
+		case '0' <= c && c <= '9':
+			digit = true
+		case 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+			letter = true
+		default:
+			return false
+		}
+	}
+	return digit && letter
+}
+
+// Reports whether s looks like a base64url encoded hash. Words, including CamelCase ones such as
+// 'SemiBold', and numbers are told apart from hashes by requiring both letters and digits.
+//
+// Some hashes have no digits and aren't recognized, which only costs them the long-lived caching,
+// while mistaking a word for a hash would cache a file that changes as immutable.
+func isBase64Hash(s string) bool {
+	var digit, letter bool
+	for _, c := range s {
+		switch {
+		case '0' <= c && c <= '9':
+			digit = true
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+			letter = true
+		case c == '-' || c == '_':
+		default:
+			return false
+		}
 	}
+	return digit && letter
 }
@@ -0,0 +1,87 @@
+package fileserver
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsFingerprinted(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		{name: "app.3f9a1c.js", expected: true},
+		{name: "assets/main.3f9a1c2b.chunk.js", expected: true},
+		{name: "index-BkZ3a9xQ.js", expected: true},
+		{name: "assets/index-D-9eFq2a.css", expected: true},
+		{name: "chunk-4F3A2B1C.js", expected: true},
+		{name: "app.js", expected: false},
+		{name: "robots.txt", expected: false},
+		{name: "index.html", expected: false},
+		{name: "jquery.min.js", expected: false},
+		{name: "jquery-3.7.1.min.js", expected: false},
+		{name: "reset-password.js", expected: false},
+		{name: "report.20240101.json", expected: false},
+		{name: "app.3f9a1c", expected: false},
+		{name: "style.facade.css", expected: false},
+		{name: "app.decade.js", expected: false},
+		{name: "logo.beaded.png", expected: false},
+		{name: "fonts/OpenSans-SemiBold.woff2", expected: false},
+		{name: "Poppins-SemiBold.ttf", expected: false},
+		{name: "my-component-Settings.js", expected: false},
+		{name: "styles.DarkTheme.css", expected: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result := IsFingerprinted(tt.name)
+			if result != tt.expected {
+				t.Errorf("expected result to be %t but got %t", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	policy := CachePolicy(CacheRevalidate,
+		GlobRule("robots.txt", "public, max-age=3600"),
+		FingerprintRule(CacheImmutable),
+		GlobRule("static/*", "public, max-age=600"),
+		ExtRule("public, max-age=86400", "woff2", ".PNG"),
+	)
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "index.html", expected: CacheRevalidate},
+		{path: "robots.txt", expected: "public, max-age=3600"},
+		{path: "/nested/robots.txt", expected: "public, max-age=3600"},
+		{path: "assets/app.3f9a1c.js", expected: CacheImmutable},
+		{path: "static/app.js", expected: "public, max-age=600"},
+		{path: "static/nested/app.js", expected: CacheRevalidate},
+		{path: "fonts/inter.woff2", expected: "public, max-age=86400"},
+		{path: "logo.png", expected: "public, max-age=86400"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.path, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.URL.Path = tt.path
+
+			result := policy(r)
+			if result != tt.expected {
+				t.Errorf("expected Cache-Control to be %q but got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGlobRuleInvalidPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected GlobRule to panic")
+		}
+	}()
+	GlobRule("[", CacheImmutable)
+}
@@ -0,0 +1,142 @@
+package fileserver
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompressionCache(t *testing.T) {
+	c := newCompressionCache(10)
+
+	c.add("a", []byte("aaaa"))
+	c.add("b", []byte("bbbb"))
+	// Mark 'a' as recently used, so 'b' is evicted next.
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	c.add("c", []byte("cccc"))
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if data, ok := c.get("a"); !ok || string(data) != "aaaa" {
+		t.Error("expected a to be cached")
+	}
+	if data, ok := c.get("c"); !ok || string(data) != "cccc" {
+		t.Error("expected c to be cached")
+	}
+	if c.size != 8 {
+		t.Errorf("expected cache size to be 8 but got %d", c.size)
+	}
+
+	// Values larger than the budget are never cached.
+	c.add("d", []byte("ddddddddddd"))
+	if _, ok := c.get("d"); ok {
+		t.Error("expected d not to be cached")
+	}
+
+	// Replacing a value updates the cache size.
+	c.add("a", []byte("aa"))
+	if c.size != 6 {
+		t.Errorf("expected cache size to be 6 but got %d", c.size)
+	}
+}
+
+func TestCompressionCacheDo(t *testing.T) {
+	c := newCompressionCache(1 << 10)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("compressed"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.do("key", fn)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if string(data) != "compressed" {
+				t.Errorf("expected data to be compressed but got %s", data)
+			}
+		}()
+	}
+	// Give the goroutines time to wait on the in-flight call.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected fn to be called once but got %d", n)
+	}
+
+	// Errors are not cached.
+	_, err := c.do("error", func() ([]byte, error) {
+		return nil, errors.New("compression failed")
+	})
+	if err == nil {
+		t.Error("expected error")
+	}
+	if _, ok := c.get("error"); ok {
+		t.Error("expected error not to be cached")
+	}
+}
+
+type fakeFileInfo struct {
+	fs.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+
+func TestETagCache(t *testing.T) {
+	now := time.Now()
+	c := newETagCache(2)
+
+	c.add("a", fakeFileInfo{size: 1, modTime: now}, fileHashes{etag: `"a"`})
+	c.add("b", fakeFileInfo{size: 1, modTime: now}, fileHashes{etag: `"b"`})
+
+	if hashes, ok := c.get("a", fakeFileInfo{size: 1, modTime: now}); !ok || hashes.etag != `"a"` {
+		t.Errorf("expected a to be cached but got %s", hashes.etag)
+	}
+
+	// Stale entries are never returned.
+	if _, ok := c.get("a", fakeFileInfo{size: 2, modTime: now}); ok {
+		t.Error("expected a to be stale after changing size")
+	}
+	c.add("a", fakeFileInfo{size: 1, modTime: now}, fileHashes{etag: `"a"`})
+	if _, ok := c.get("a", fakeFileInfo{size: 1, modTime: now.Add(time.Second)}); ok {
+		t.Error("expected a to be stale after changing modtime")
+	}
+
+	// Least recently used entries are evicted.
+	c.add("a", fakeFileInfo{size: 1, modTime: now}, fileHashes{etag: `"a"`})
+	c.add("c", fakeFileInfo{size: 1, modTime: now}, fileHashes{etag: `"c"`})
+	if _, ok := c.get("b", fakeFileInfo{size: 1, modTime: now}); ok {
+		t.Error("expected b to be evicted")
+	}
+	if c.ll.Len() != 2 {
+		t.Errorf("expected cache to have 2 entries but got %d", c.ll.Len())
+	}
+
+	c.invalidate("a")
+	if _, ok := c.get("a", fakeFileInfo{size: 1, modTime: now}); ok {
+		t.Error("expected a to be invalidated")
+	}
+	c.invalidate()
+	if _, ok := c.get("c", fakeFileInfo{size: 1, modTime: now}); ok {
+		t.Error("expected c to be invalidated")
+	}
+}
@@ -16,14 +16,32 @@ type config struct {
 	spa      bool
 	fallback string
 	silent   bool
+	list     bool
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		var run func(args []string) error
+		switch os.Args[1] {
+		case "precompress":
+			run = precompress
+		case "manifest":
+			run = manifest
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	var cfg config
 	flag.StringVar(&cfg.addr, "addr", ":8000", "Sets the server listen address.")
 	flag.BoolVar(&cfg.spa, "spa", false, "Sets the server in SPA mode.")
 	flag.StringVar(&cfg.fallback, "fallback", "index.html", "Sets the SPA fallback file.")
 	flag.BoolVar(&cfg.silent, "silent", false, "Disables request logging.")
+	flag.BoolVar(&cfg.list, "list", false, "Lists the contents of directories without an index file.")
 	flag.Parse()
 
 	dir := flag.Arg(0)
@@ -41,7 +59,7 @@ func main() {
 		h = http.StripPrefix("/", fileserver.ServeSPA(os.DirFS(dir), cfg.fallback))
 	} else {
 		log.Printf("Serving %q on %q\n", dir, cfg.addr)
-		h = http.StripPrefix("/", fileserver.Serve(dir))
+		h = http.StripPrefix("/", fileserver.Serve(dir, fileserver.WithDirListing(cfg.list)))
 	}
 
 	log.Fatal(http.ListenAndServe(cfg.addr, logger(h)))
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ffss92/fileserver"
+)
+
+// ETag functions available to the manifest command.
+var manifestETagFuncs = map[string]fileserver.ETagFunc{
+	"md5":    fileserver.MD5ETag,
+	"sha256": fileserver.SHA256ETag,
+	"xxhash": fileserver.XXHashETag,
+	"fnv":    fileserver.FNVETag,
+}
+
+// Runs the manifest command, which writes the JSON manifest of the files in a directory.
+func manifest(args []string) error {
+	var (
+		etag   string
+		output string
+	)
+	fset := flag.NewFlagSet("manifest", flag.ExitOnError)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: fileserver manifest [flags] <dir>\n\n")
+		fmt.Fprintf(fset.Output(), "Writes the JSON manifest of the files in dir, with their ETags, sizes and SHA-256 hashes.\n\n")
+		fset.PrintDefaults()
+	}
+	fset.StringVar(&etag, "etag", "md5", "Sets the ETag function: md5, sha256, xxhash or fnv. Must match the server's.")
+	fset.StringVar(&output, "o", "", "Writes the manifest to this file instead of the standard output.")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	dir := fset.Arg(0)
+	if dir == "" {
+		fset.Usage()
+		return errors.New("please provide a target")
+	}
+
+	etagFn, ok := manifestETagFuncs[etag]
+	if !ok {
+		return fmt.Errorf("unsupported etag function %q", etag)
+	}
+
+	m, err := fileserver.BuildManifest(os.DirFS(dir), etagFn)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/ffss92/fileserver"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Sidecar files written by the precompress command, named after the source file with the
+// encoding extension appended, for example 'app.js.br'. This is the naming convention the
+// server uses to look up precompressed files.
+var precompressFormats = []struct {
+	encoding string
+	ext      string
+	encode   fileserver.EncoderFunc
+}{
+	{
+		encoding: "br",
+		ext:      ".br",
+		encode: func(w io.Writer) (io.WriteCloser, error) {
+			return brotli.NewWriterLevel(w, brotli.BestCompression), nil
+		},
+	},
+	{
+		encoding: "zstd",
+		ext:      ".zst",
+		encode: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		},
+	},
+	{
+		encoding: "gzip",
+		ext:      ".gz",
+		encode: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, gzip.BestCompression)
+		},
+	},
+}
+
+type precompressConfig struct {
+	minSize     int64
+	encodings   string
+	include     globList
+	exclude     globList
+	keepSmaller bool
+	force       bool
+	state       string
+}
+
+// globList is a repeatable flag of glob patterns.
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globList) Set(value string) error {
+	if _, err := path.Match(value, ""); err != nil {
+		return fmt.Errorf("invalid glob %q: %w", value, err)
+	}
+	*g = append(*g, value)
+	return nil
+}
+
+// Matches reports whether any of the patterns matches the slash-separated path relative to the
+// walked directory, or its base name.
+func (g globList) matches(name string) bool {
+	for _, pattern := range g {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the precompress command, which writes '.br', '.zst' and '.gz' siblings of every
+// file in a directory.
+func precompress(args []string) error {
+	var cfg precompressConfig
+	fset := flag.NewFlagSet("precompress", flag.ExitOnError)
+	fset.Usage = func() {
+		fmt.Fprintf(fset.Output(), "Usage: fileserver precompress [flags] <dir>\n\n")
+		fmt.Fprintf(fset.Output(), "Writes name.ext.br, name.ext.zst and name.ext.gz siblings of the files in dir.\n\n")
+		fset.PrintDefaults()
+	}
+	fset.Int64Var(&cfg.minSize, "min-size", 1024, "Skips files smaller than this size, in bytes.")
+	fset.StringVar(&cfg.encodings, "encodings", "br,zstd,gzip", "Comma-separated list of encodings to write.")
+	fset.Var(&cfg.include, "include", "Only compresses files matching this glob. Can be repeated.")
+	fset.Var(&cfg.exclude, "exclude", "Skips files matching this glob. Can be repeated.")
+	fset.BoolVar(&cfg.keepSmaller, "keep-smaller", true, "Only keeps compressed files smaller than the original.")
+	fset.BoolVar(&cfg.force, "force", false, "Compresses files even if their compressed siblings are up to date.")
+	fset.StringVar(&cfg.state, "state", "", "File recording the siblings dropped by -keep-smaller, outside dir. Defaults to a file in the user cache dir.")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	dir := fset.Arg(0)
+	if dir == "" {
+		fset.Usage()
+		return errors.New("please provide a target")
+	}
+	if cfg.state == "" {
+		state, err := defaultStatePath(dir)
+		if err != nil {
+			return err
+		}
+		cfg.state = state
+	}
+
+	written, skipped, err := precompressDir(dir, cfg)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Precompressed %q: %d files written, %d skipped\n", dir, written, skipped)
+	return nil
+}
+
+// Writes the compressed siblings of every file in dir, returning how many were written and how
+// many were skipped because they were up to date or not smaller than the original.
+func precompressDir(dir string, cfg precompressConfig) (written, skipped int, err error) {
+	encodings := strings.Split(cfg.encodings, ",")
+	for _, encoding := range encodings {
+		if !isPrecompressEncoding(encoding) {
+			return 0, 0, fmt.Errorf("unsupported encoding %q", encoding)
+		}
+	}
+	if cfg.state != "" {
+		// Everything in dir is served, so the state file would be published with it.
+		inside, err := isInside(dir, cfg.state)
+		if err != nil {
+			return 0, 0, err
+		}
+		if inside {
+			return 0, 0, fmt.Errorf("state file %s must be outside %s", cfg.state, dir)
+		}
+	}
+
+	rejected, err := loadRejected(cfg.state)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, fpath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !cfg.shouldCompress(name) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() < cfg.minSize {
+			return nil
+		}
+
+		for _, format := range precompressFormats {
+			if !slices.Contains(encodings, format.encoding) {
+				continue
+			}
+			ok, err := cfg.compressFile(fpath, info, format.ext, format.encode, rejected.entry(name+format.ext))
+			if err != nil {
+				return fmt.Errorf("failed to compress %s with %s: %w", name, format.encoding, err)
+			}
+			if ok {
+				written++
+			} else {
+				skipped++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return written, skipped, err
+	}
+
+	return written, skipped, rejected.save(cfg.state)
+}
+
+// Returns the default path of the state file of dir, which records the siblings that were dropped
+// because they weren't smaller than the original. Without it, those files would be compressed again
+// on every run. It's kept in the user cache dir, named after the absolute path of dir, so it isn't
+// deployed with the files.
+func defaultStatePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the state dir, please provide -state: %w", err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(cache, "fileserver", "precompress", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Reports whether fpath is inside dir.
+func isInside(dir, fpath string) (bool, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	absPath, err := filepath.Abs(fpath)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// rejectedSet maps the slash-separated name of a dropped sibling to the modification time, in
+// Unix nanoseconds, of the source file it was compressed from.
+type rejectedSet map[string]int64
+
+// rejectedEntry is a handle to a single sibling in a rejectedSet.
+type rejectedEntry struct {
+	set  rejectedSet
+	name string
+}
+
+func (r rejectedSet) entry(name string) rejectedEntry {
+	return rejectedEntry{set: r, name: name}
+}
+
+// Reports whether the sibling was dropped when the source file had the given modification time.
+func (e rejectedEntry) matches(modTime time.Time) bool {
+	t, ok := e.set[e.name]
+	return ok && t == modTime.UnixNano()
+}
+
+func (e rejectedEntry) record(modTime time.Time) {
+	e.set[e.name] = modTime.UnixNano()
+}
+
+func (e rejectedEntry) clear() {
+	delete(e.set, e.name)
+}
+
+// Reads the rejected siblings recorded in the state file by a previous run. A missing file, or no
+// state file at all, is an empty set.
+func loadRejected(state string) (rejectedSet, error) {
+	rejected := make(rejectedSet)
+	if state == "" {
+		return rejected, nil
+	}
+	b, err := os.ReadFile(state)
+	if errors.Is(err, fs.ErrNotExist) {
+		return rejected, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &rejected); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", state, err)
+	}
+	return rejected, nil
+}
+
+// Writes the rejected siblings to the state file, removing it when there are none.
+func (r rejectedSet) save(state string) error {
+	if state == "" {
+		return nil
+	}
+	if len(r) == 0 {
+		if err := os.Remove(state); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(state), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(state, b, 0o644)
+}
+
+// Reports whether the file should be compressed, based on the include and exclude globs. Compressed
+// siblings and files with content types that are already compressed are always skipped.
+func (cfg precompressConfig) shouldCompress(name string) bool {
+	for _, format := range precompressFormats {
+		if strings.HasSuffix(name, format.ext) {
+			return false
+		}
+	}
+	if len(cfg.include) > 0 && !cfg.include.matches(name) {
+		return false
+	}
+	if cfg.exclude.matches(name) {
+		return false
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	return contentType == "" || fileserver.DefaultCompressionPolicy(name, contentType, 0)
+}
+
+// Writes the compressed sibling of the file at fpath, named fpath+ext. It reports whether the file
+// was written, which doesn't happen if the sibling is newer than the file or, when keepSmaller is set,
+// if the compressed file isn't smaller than the original. Dropped siblings are recorded in rejected,
+// so they're only compressed again once the file changes.
+func (cfg precompressConfig) compressFile(fpath string, info fs.FileInfo, ext string, encode fileserver.EncoderFunc, rejected rejectedEntry) (bool, error) {
+	target := fpath + ext
+	if !cfg.force {
+		if sidecar, err := os.Stat(target); err == nil && !sidecar.ModTime().Before(info.ModTime()) {
+			return false, nil
+		}
+		if cfg.keepSmaller && rejected.matches(info.ModTime()) {
+			return false, nil
+		}
+	}
+
+	src, err := os.Open(fpath)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+
+	// Written to a temporary file first, so the server never sees a partially written sibling.
+	tmp, err := os.CreateTemp(filepath.Dir(fpath), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	enc, err := encode(tmp)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(enc, src); err != nil {
+		return false, err
+	}
+	if err := enc.Close(); err != nil {
+		return false, err
+	}
+
+	stat, err := tmp.Stat()
+	if err != nil {
+		return false, err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+
+	if cfg.keepSmaller && stat.Size() >= info.Size() {
+		// A stale sibling would be served in place of the updated file.
+		if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		rejected.record(info.ModTime())
+		return false, nil
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return false, err
+	}
+	rejected.clear()
+	log.Printf("%s (%d -> %d bytes)\n", target, info.Size(), stat.Size())
+	return true, nil
+}
+
+func isPrecompressEncoding(encoding string) bool {
+	for _, format := range precompressFormats {
+		if format.encoding == encoding {
+			return true
+		}
+	}
+	return false
+}
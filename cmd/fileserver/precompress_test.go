@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var compressible = []byte(strings.Repeat("console.log('hello, world');\n", 100))
+
+func writeTestFiles(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fpath, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func exists(t *testing.T, fpath string) bool {
+	t.Helper()
+	_, err := os.Stat(fpath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestPrecompressDir(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      precompressConfig
+		expected map[string]bool
+	}{
+		{
+			name: "defaults",
+			cfg:  precompressConfig{minSize: 1024, encodings: "gzip"},
+			expected: map[string]bool{
+				"app.js":         true,
+				"assets/app.css": true,
+				"small.js":       false,
+				"photo.png":      false,
+			},
+		},
+		{
+			name: "include",
+			cfg:  precompressConfig{minSize: 1024, encodings: "gzip", include: globList{"*.css"}},
+			expected: map[string]bool{
+				"app.js":         false,
+				"assets/app.css": true,
+			},
+		},
+		{
+			name: "include path",
+			cfg:  precompressConfig{minSize: 1024, encodings: "gzip", include: globList{"assets/*"}},
+			expected: map[string]bool{
+				"app.js":         false,
+				"assets/app.css": true,
+			},
+		},
+		{
+			name: "exclude",
+			cfg:  precompressConfig{minSize: 1024, encodings: "gzip", exclude: globList{"*.css"}},
+			expected: map[string]bool{
+				"app.js":         true,
+				"assets/app.css": false,
+			},
+		},
+		{
+			name: "min size",
+			cfg:  precompressConfig{minSize: 0, encodings: "gzip"},
+			expected: map[string]bool{
+				"app.js":   true,
+				"small.js": true,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeTestFiles(t, map[string][]byte{
+				"app.js":         compressible,
+				"assets/app.css": compressible,
+				"small.js":       []byte(strings.Repeat("a", 100)),
+				"photo.png":      compressible,
+			})
+
+			if _, _, err := precompressDir(dir, tc.cfg); err != nil {
+				t.Fatal(err)
+			}
+			for name, expected := range tc.expected {
+				if got := exists(t, filepath.Join(dir, name+".gz")); got != expected {
+					t.Errorf("expected %s.gz to exist to be %v but got %v", name, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPrecompressDirUnsupportedEncoding(t *testing.T) {
+	dir := writeTestFiles(t, map[string][]byte{"app.js": compressible})
+	_, _, err := precompressDir(dir, precompressConfig{encodings: "gzip,lzma"})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported encoding")
+	}
+}
+
+func TestPrecompressDirWritesSibling(t *testing.T) {
+	dir := writeTestFiles(t, map[string][]byte{"app.js": compressible})
+	state := filepath.Join(t.TempDir(), "state.json")
+
+	written, skipped, err := precompressDir(dir, precompressConfig{encodings: "gzip,br", keepSmaller: true, state: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 2 || skipped != 0 {
+		t.Errorf("expected 2 written and 0 skipped but got %d and %d", written, skipped)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "app.js.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, compressible) {
+		t.Error("expected the sibling to decompress to the original file")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != "app.js" && name != "app.js.gz" && name != "app.js.br" {
+			t.Errorf("expected only the siblings to be written but found %s", name)
+		}
+	}
+	if exists(t, state) {
+		t.Error("expected the state file not to be written without rejected siblings")
+	}
+}
+
+func TestPrecompressDirUpToDate(t *testing.T) {
+	dir := writeTestFiles(t, map[string][]byte{"app.js": compressible})
+	sibling := filepath.Join(dir, "app.js.gz")
+	if err := os.WriteFile(sibling, []byte("up to date"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(sibling, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := precompressConfig{encodings: "gzip", keepSmaller: true}
+	written, skipped, err := precompressDir(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 || skipped != 1 {
+		t.Errorf("expected 0 written and 1 skipped but got %d and %d", written, skipped)
+	}
+	if b, _ := os.ReadFile(sibling); string(b) != "up to date" {
+		t.Error("expected an up to date sibling not to be rewritten")
+	}
+
+	cfg.force = true
+	written, _, err = precompressDir(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 {
+		t.Errorf("expected force to write 1 sibling but got %d", written)
+	}
+}
+
+func TestPrecompressDirKeepSmaller(t *testing.T) {
+	dir := writeTestFiles(t, map[string][]byte{"data.txt": randomBytes(t, 4096)})
+	sibling := filepath.Join(dir, "data.txt.gz")
+	if err := os.WriteFile(sibling, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(sibling, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	state := filepath.Join(t.TempDir(), "precompress", "state.json")
+
+	written, skipped, err := precompressDir(dir, precompressConfig{encodings: "gzip", keepSmaller: true, state: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 || skipped != 1 {
+		t.Errorf("expected 0 written and 1 skipped but got %d and %d", written, skipped)
+	}
+	if exists(t, sibling) {
+		t.Error("expected the stale sibling to be removed")
+	}
+	if !exists(t, state) {
+		t.Error("expected the rejected sibling to be recorded in the state file")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the original file to be left in dir but got %d files", len(entries))
+	}
+
+	written, _, err = precompressDir(dir, precompressConfig{encodings: "gzip", keepSmaller: false, state: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 || !exists(t, sibling) {
+		t.Error("expected the sibling to be written without keep-smaller")
+	}
+	if exists(t, state) {
+		t.Error("expected the state file to be removed once the sibling is written")
+	}
+}
+
+func TestPrecompressDirStateInside(t *testing.T) {
+	dir := writeTestFiles(t, map[string][]byte{"app.js": compressible})
+	cfg := precompressConfig{encodings: "gzip", state: filepath.Join(dir, "nested", "state.json")}
+	if _, _, err := precompressDir(dir, cfg); err == nil {
+		t.Fatal("expected an error for a state file inside dir")
+	}
+
+	cfg.state = filepath.Join(dir+"-state", "state.json")
+	if _, _, err := precompressDir(dir, cfg); err != nil {
+		t.Fatalf("unexpected error for a state file next to dir: %s", err)
+	}
+}
+
+func TestCompressFileRemembersRejected(t *testing.T) {
+	dir := writeTestFiles(t, map[string][]byte{"data.txt": randomBytes(t, 4096)})
+	fpath := filepath.Join(dir, "data.txt")
+
+	var calls int
+	encode := func(w io.Writer) (io.WriteCloser, error) {
+		calls++
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	}
+
+	cfg := precompressConfig{keepSmaller: true}
+	rejected := make(rejectedSet)
+	for i := 0; i < 2; i++ {
+		info, err := os.Stat(fpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := cfg.compressFile(fpath, info, ".gz", encode, rejected.entry("data.txt.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Error("expected the sibling not to be written")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the file to be compressed once but got %d", calls)
+	}
+
+	// Changing the file compresses it again.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fpath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.compressFile(fpath, info, ".gz", encode, rejected.entry("data.txt.gz")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected a changed file to be compressed again but got %d calls", calls)
+	}
+}
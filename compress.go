@@ -1,12 +1,202 @@
 package fileserver
 
 import (
+	"io"
+	"io/fs"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// Checks if the request accepts gzip encoded responses.
-func acceptsGzip(r *http.Request) bool {
-	acceptEncoding := r.Header.Get("Accept-Encoding")
-	return strings.Contains(acceptEncoding, "gzip")
+// The identity content-coding, meaning no encoding at all.
+const identity = "identity"
+
+// File extensions of the sidecar files looked up next to the requested file when serving
+// precompressed content. For example, a request for 'app.js' may be served from 'app.js.br'.
+var sidecarExts = map[string]string{
+	"br":   ".br",
+	"zstd": ".zst",
+	"gzip": ".gz",
+}
+
+// acceptEncoding holds the quality values of the content-codings listed in the Accept-Encoding
+// request header, as described by RFC 9110, section 12.5.3. A nil acceptEncoding means the
+// request didn't send the header.
+type acceptEncoding map[string]float64
+
+// Parses the Accept-Encoding header of r. Codings are case-insensitive and members with
+// invalid quality values are ignored.
+func parseAcceptEncoding(r *http.Request) acceptEncoding {
+	values := r.Header.Values("Accept-Encoding")
+	if len(values) == 0 {
+		return nil
+	}
+
+	accept := make(acceptEncoding)
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(member, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" {
+				continue
+			}
+			// x-gzip is an alias of gzip (RFC 9110, section 8.4.1.3).
+			if coding == "x-gzip" {
+				coding = "gzip"
+			}
+
+			q, ok := parseQuality(params)
+			if !ok {
+				continue
+			}
+			// Keep the highest quality if a coding is listed more than once.
+			if prev, found := accept[coding]; !found || q > prev {
+				accept[coding] = q
+			}
+		}
+	}
+	return accept
+}
+
+// Parses the weight of an Accept-Encoding member from its parameters, such as ' q=0.5'.
+// The weight defaults to 1 if not present.
+func parseQuality(params string) (float64, bool) {
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || v < 0 || v > 1 {
+			return 0, false
+		}
+		q = v
+	}
+	return q, true
+}
+
+// Returns the quality value of coding and whether it was set explicitly, either by naming
+// the coding or through the '*' wildcard.
+func (a acceptEncoding) quality(coding string) (float64, bool) {
+	if q, ok := a[coding]; ok {
+		return q, true
+	}
+	if q, ok := a["*"]; ok {
+		return q, true
+	}
+	return 0, false
+}
+
+// Reports whether the client accepts coding. Without an Accept-Encoding header, only identity
+// is considered acceptable, since this is what clients that don't send it expect.
+func (a acceptEncoding) accepts(coding string) bool {
+	if a == nil {
+		return coding == identity
+	}
+	q, explicit := a.quality(coding)
+	if !explicit && coding == identity {
+		return true
+	}
+	return q > 0
+}
+
+// Picks the content-coding of the response from offered, which must be ordered by server preference.
+// The identity coding is always considered last and is chosen only if no other coding has a greater
+// quality value. If nothing is acceptable, false is returned and the server should respond with
+// 406 Not Acceptable.
+func (a acceptEncoding) negotiate(offered []string) (string, bool) {
+	if a == nil {
+		return identity, true
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, coding := range offered {
+		if q, _ := a.quality(coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+
+	// Identity is acceptable unless explicitly excluded, either by 'identity;q=0' or '*;q=0'.
+	q, explicit := a.quality(identity)
+	if !explicit && best == "" {
+		return identity, true
+	}
+	if q > bestQ {
+		return identity, true
+	}
+	if best == "" {
+		return "", false
+	}
+	return best, true
+}
+
+// A precompressed sidecar of a file.
+type sidecar struct {
+	name string
+	file fs.File
+	stat fs.FileInfo
+}
+
+// Opens the precompressed sidecars of name for the given encodings that are accepted by the client.
+// Callers are responsible for closing the returned files.
+func openSidecars(fsys fs.FS, accept acceptEncoding, name string, encodings []string) (map[string]sidecar, error) {
+	found := make(map[string]sidecar)
+	for _, encoding := range encodings {
+		ext, ok := sidecarExts[encoding]
+		if !ok || !accept.accepts(encoding) {
+			continue
+		}
+		sidecarName := name + ext
+		f, err := fsys.Open(sidecarName)
+		if err != nil {
+			continue
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			closeSidecars(found)
+			return nil, fsError("stat", sidecarName, err)
+		}
+		if stat.IsDir() {
+			f.Close()
+			continue
+		}
+		found[encoding] = sidecar{name: sidecarName, file: f, stat: stat}
+	}
+	return found, nil
+}
+
+// Closes all sidecar files.
+func closeSidecars(sidecars map[string]sidecar) {
+	for _, s := range sidecars {
+		s.file.Close()
+	}
+}
+
+// encodedResponseWriter sets the Content-Encoding header right before the response headers are
+// written.
+//
+// This allows [http.ServeContent] to compute the Content-Length and Content-Range headers for the
+// encoded content, which it skips when the Content-Encoding header is already present.
+type encodedResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+}
+
+func (e *encodedResponseWriter) WriteHeader(status int) {
+	if status < 300 || status == http.StatusNotModified {
+		e.Header().Set("Content-Encoding", e.encoding)
+	}
+	e.ResponseWriter.WriteHeader(status)
+}
+
+// Serves content encoded with the given content-coding using [http.ServeContent].
+func serveEncoded(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker, encoding string) {
+	ew := &encodedResponseWriter{ResponseWriter: w, encoding: encoding}
+	http.ServeContent(ew, r, name, modtime, content)
 }
@@ -5,47 +5,151 @@ import (
 	"testing"
 )
 
-func TestAcceptsGzip(t *testing.T) {
+func TestNegotiateEncoding(t *testing.T) {
 	testCases := []struct {
-		name       string
-		newRequest func() (*http.Request, error)
-		expected   bool
+		name           string
+		acceptEncoding []string
+		offered        []string
+		expected       string
+		acceptable     bool
 	}{
 		{
-			name: "accepts",
-			newRequest: func() (*http.Request, error) {
-				req, err := http.NewRequest(http.MethodGet, "/", nil)
-				if err != nil {
-					return nil, err
-				}
-				req.Header.Set("Accept-Encoding", "br, gzip")
-				return req, nil
-			},
-			expected: true,
-		},
-		{
-			name: "dont accept",
-			newRequest: func() (*http.Request, error) {
-				req, err := http.NewRequest(http.MethodGet, "/", nil)
-				if err != nil {
-					return nil, err
-				}
-				req.Header.Set("Accept-Encoding", "br")
-				return req, nil
-			},
-			expected: false,
+			name:           "accepts",
+			acceptEncoding: []string{"br, gzip"},
+			offered:        []string{"gzip"},
+			expected:       "gzip",
+			acceptable:     true,
+		},
+		{
+			name:           "dont accept",
+			acceptEncoding: []string{"br"},
+			offered:        []string{"gzip"},
+			expected:       identity,
+			acceptable:     true,
+		},
+		{
+			name:           "no header",
+			acceptEncoding: nil,
+			offered:        []string{"br", "gzip"},
+			expected:       identity,
+			acceptable:     true,
+		},
+		{
+			name:           "empty header",
+			acceptEncoding: []string{""},
+			offered:        []string{"gzip"},
+			expected:       identity,
+			acceptable:     true,
+		},
+		{
+			name:           "server priority on ties",
+			acceptEncoding: []string{"gzip, zstd, br"},
+			offered:        []string{"br", "zstd", "gzip"},
+			expected:       "br",
+			acceptable:     true,
+		},
+		{
+			name:           "client quality",
+			acceptEncoding: []string{"br;q=0.5, gzip;q=0.8"},
+			offered:        []string{"br", "gzip"},
+			expected:       "gzip",
+			acceptable:     true,
+		},
+		{
+			name:           "excluded coding",
+			acceptEncoding: []string{"gzip;q=0"},
+			offered:        []string{"gzip"},
+			expected:       identity,
+			acceptable:     true,
+		},
+		{
+			name:           "case insensitive with spaces",
+			acceptEncoding: []string{"GZIP ; Q=0.3"},
+			offered:        []string{"gzip"},
+			expected:       "gzip",
+			acceptable:     true,
+		},
+		{
+			name:           "x-gzip alias",
+			acceptEncoding: []string{"x-gzip"},
+			offered:        []string{"gzip"},
+			expected:       "gzip",
+			acceptable:     true,
+		},
+		{
+			name:           "wildcard",
+			acceptEncoding: []string{"*"},
+			offered:        []string{"zstd", "gzip"},
+			expected:       "zstd",
+			acceptable:     true,
+		},
+		{
+			name:           "wildcard with exclusion",
+			acceptEncoding: []string{"*, zstd;q=0"},
+			offered:        []string{"zstd", "gzip"},
+			expected:       "gzip",
+			acceptable:     true,
+		},
+		{
+			name:           "identity preferred",
+			acceptEncoding: []string{"identity, gzip;q=0.5"},
+			offered:        []string{"gzip"},
+			expected:       identity,
+			acceptable:     true,
+		},
+		{
+			name:           "identity excluded",
+			acceptEncoding: []string{"identity;q=0"},
+			offered:        nil,
+			expected:       "",
+			acceptable:     false,
+		},
+		{
+			name:           "wildcard excludes identity",
+			acceptEncoding: []string{"*;q=0"},
+			offered:        []string{"gzip"},
+			expected:       "",
+			acceptable:     false,
+		},
+		{
+			name:           "identity excluded with compression",
+			acceptEncoding: []string{"gzip, identity;q=0"},
+			offered:        []string{"gzip"},
+			expected:       "gzip",
+			acceptable:     true,
+		},
+		{
+			name:           "invalid quality ignored",
+			acceptEncoding: []string{"gzip;q=2, br;q=foo"},
+			offered:        []string{"br", "gzip"},
+			expected:       identity,
+			acceptable:     true,
+		},
+		{
+			name:           "multiple header lines",
+			acceptEncoding: []string{"gzip;q=0.5", "zstd"},
+			offered:        []string{"zstd", "gzip"},
+			expected:       "zstd",
+			acceptable:     true,
 		},
 	}
 
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
-			req, err := tt.newRequest()
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
 			if err != nil {
 				t.Fatalf("unexpected error creating request: %s", err)
 			}
-			result := acceptsGzip(req)
+			for _, value := range tt.acceptEncoding {
+				req.Header.Add("Accept-Encoding", value)
+			}
+
+			result, ok := parseAcceptEncoding(req).negotiate(tt.offered)
+			if ok != tt.acceptable {
+				t.Fatalf("expected acceptable to be %t but got %t", tt.acceptable, ok)
+			}
 			if result != tt.expected {
-				t.Errorf("expected result to be %t but got %t", tt.expected, result)
+				t.Errorf("expected result to be %q but got %q", tt.expected, result)
 			}
 		})
 	}
@@ -0,0 +1,222 @@
+package fileserver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content-coding of responses delta-compressed with zstd against a dictionary, as defined by
+// the Compression Dictionary Transport specification (RFC 9842).
+//
+// The brotli counterpart, 'dcb', isn't supported, since the brotli encoder doesn't support
+// custom dictionaries.
+const dictionaryZstd = "dcz"
+
+// Magic number that starts every 'dcz' response, followed by the SHA-256 hash of the dictionary.
+const dczMagic = "\x5e\x2a\x4d\x18\x20\x00\x00\x00"
+
+// Memory budget of the cache of dictionary contents, 64mb.
+const dictionaryCacheSize = 64 << 20
+
+// Minimum time between rebuilds of the index of dictionaries, which are triggered by requests for
+// dictionaries missing from it.
+const dictionaryIndexRefresh = time.Minute
+
+// Function used to select the files that clients should keep as compression dictionaries for future
+// requests. It returns the URL pattern sent in the 'match' parameter of the Use-As-Dictionary response
+// header, for example "/assets/app.*.js", or an empty string if the file isn't a dictionary.
+//
+// It must not depend on the request, since it's also used to find the dictionaries referenced by the
+// Available-Dictionary request header.
+type DictionaryMatchFunc func(name string) string
+
+// dictionaries finds the dictionaries advertised by clients in the Available-Dictionary header.
+//
+// Dictionaries are looked up by their SHA-256 hash among the files selected by the match function, in the
+// served [fs.FS] and in additional sources, such as the directory of a previous deploy. The index of hashes
+// is built on first use, and rebuilt when a dictionary is missing from it, at most once per refresh interval,
+// so files added to the sources later are found too.
+type dictionaries struct {
+	match   DictionaryMatchFunc
+	sources []fs.FS
+	refresh time.Duration
+
+	building  sync.Mutex // Held while the index is built.
+	mu        sync.RWMutex
+	index     map[[sha256.Size]byte]dictionaryFile
+	indexedAt time.Time
+	contents  *compressionCache
+}
+
+type dictionaryFile struct {
+	fsys fs.FS
+	name string
+}
+
+// A dictionary available to both the client and the server.
+type dictionary struct {
+	hash    [sha256.Size]byte
+	content []byte
+}
+
+// Creates the dictionaries selected by match, looked up in sources.
+func newDictionaries(match DictionaryMatchFunc, sources []fs.FS) *dictionaries {
+	return &dictionaries{
+		match:    match,
+		sources:  sources,
+		refresh:  dictionaryIndexRefresh,
+		contents: newCompressionCache(dictionaryCacheSize),
+	}
+}
+
+// Returns the Use-As-Dictionary header value for the file, if it's a dictionary.
+func (d *dictionaries) useAsDictionary(name string) string {
+	pattern := d.match(name)
+	if pattern == "" {
+		return ""
+	}
+	return "match=" + strconv.Quote(pattern)
+}
+
+// Returns the dictionary referenced by the Available-Dictionary header of r. If the header isn't present,
+// the dictionary is unknown to the server or it can't be read, a nil dictionary is returned, so the
+// response is compressed without it.
+func (d *dictionaries) lookup(r *http.Request) *dictionary {
+	hash, ok := parseAvailableDictionary(r.Header.Get("Available-Dictionary"))
+	if !ok {
+		return nil
+	}
+
+	file, ok := d.find(hash)
+	if !ok {
+		return nil
+	}
+	content, err := d.contents.do(hex.EncodeToString(hash[:]), func() ([]byte, error) {
+		return fs.ReadFile(file.fsys, file.name)
+	})
+	// The file may have changed or been removed since the index was built.
+	if err != nil || sha256.Sum256(content) != hash {
+		return nil
+	}
+	return &dictionary{hash: hash, content: content}
+}
+
+// Returns the dictionary file with the given hash, rebuilding the index if it's missing and the index
+// is older than the refresh interval.
+func (d *dictionaries) find(hash [sha256.Size]byte) (dictionaryFile, bool) {
+	if file, ok, stale := d.indexed(hash); ok || !stale {
+		return file, ok
+	}
+
+	// Only one request builds the index, the others are served without a dictionary meanwhile.
+	if !d.building.TryLock() {
+		return dictionaryFile{}, false
+	}
+	defer d.building.Unlock()
+	if file, ok, stale := d.indexed(hash); ok || !stale {
+		return file, ok
+	}
+
+	index := d.buildIndex()
+	d.mu.Lock()
+	d.index, d.indexedAt = index, time.Now()
+	d.mu.Unlock()
+
+	file, ok := index[hash]
+	return file, ok
+}
+
+// Returns the dictionary file with the given hash from the current index, and whether the index may
+// be rebuilt.
+func (d *dictionaries) indexed(hash [sha256.Size]byte) (file dictionaryFile, ok, stale bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	file, ok = d.index[hash]
+	return file, ok, d.index == nil || time.Since(d.indexedAt) >= d.refresh
+}
+
+// Hashes every dictionary file in the sources. Sources, dirs and files that can't be read are skipped,
+// such as the directory of a previous deploy that doesn't exist yet.
+func (d *dictionaries) buildIndex() map[[sha256.Size]byte]dictionaryFile {
+	index := make(map[[sha256.Size]byte]dictionaryFile)
+	for _, fsys := range d.sources {
+		_ = fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || d.match(name) == "" {
+				return nil
+			}
+
+			hash, err := hashDictionary(fsys, name)
+			if err != nil {
+				return nil
+			}
+			if _, ok := index[hash]; !ok {
+				index[hash] = dictionaryFile{fsys: fsys, name: name}
+			}
+			return nil
+		})
+	}
+	return index
+}
+
+// Returns the SHA-256 hash of the file.
+func hashDictionary(fsys fs.FS, name string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	f, err := fsys.Open(name)
+	if err != nil {
+		return hash, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return hash, err
+	}
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
+
+// Parses the Available-Dictionary request header, a structured field byte sequence holding the
+// SHA-256 hash of the dictionary, such as ':pZGm1Av0IEBKARczz7exkNYsZb8LzaMrV7J32a2fFG4=:'.
+func parseAvailableDictionary(value string) ([sha256.Size]byte, bool) {
+	var hash [sha256.Size]byte
+
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+		return hash, false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+	if err != nil || len(decoded) != sha256.Size {
+		return hash, false
+	}
+	copy(hash[:], decoded)
+	return hash, true
+}
+
+// Returns the name identifying the representation delta-compressed with this dictionary, used
+// to derive its ETag and cache key, since the compressed bytes depend on the dictionary.
+func (d *dictionary) encoding() string {
+	return dictionaryZstd + "-" + hex.EncodeToString(d.hash[:8])
+}
+
+// Returns an encoder producing 'dcz' responses against this dictionary.
+func (d *dictionary) encoder() EncoderFunc {
+	return func(w io.Writer) (io.WriteCloser, error) {
+		if _, err := io.WriteString(w, dczMagic); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(d.hash[:]); err != nil {
+			return nil, err
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithEncoderDictRaw(0, d.content))
+	}
+}
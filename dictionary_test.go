@@ -0,0 +1,240 @@
+package fileserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseAvailableDictionary(t *testing.T) {
+	hash := sha256.Sum256([]byte("dictionary"))
+	encoded := base64.StdEncoding.EncodeToString(hash[:])
+
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "valid", value: ":" + encoded + ":", valid: true},
+		{name: "spaces", value: " :" + encoded + ": ", valid: true},
+		{name: "empty", value: "", valid: false},
+		{name: "not a byte sequence", value: encoded, valid: false},
+		{name: "invalid base64", value: ":not base64:", valid: false},
+		{name: "wrong length", value: ":" + base64.StdEncoding.EncodeToString(hash[:16]) + ":", valid: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseAvailableDictionary(tt.value)
+			if ok != tt.valid {
+				t.Fatalf("expected valid to be %t but got %t", tt.valid, ok)
+			}
+			if ok && result != hash {
+				t.Error("mismatched hash")
+			}
+		})
+	}
+}
+
+func TestServerDictionaries(t *testing.T) {
+	jsDictionaries := func(name string) string {
+		if path.Ext(name) == ".js" {
+			return "/static/*.js"
+		}
+		return ""
+	}
+	h := New(os.DirFS("testdata"), WithPrecompressed(false), WithDictionaries(jsDictionaries, os.DirFS("testdata/previous")))
+
+	original, err := os.ReadFile("testdata/precompressed/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dict, err := os.ReadFile("testdata/previous/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(dict)
+	availableDictionary := ":" + base64.StdEncoding.EncodeToString(hash[:]) + ":"
+	unknownHash := sha256.Sum256([]byte("unknown"))
+
+	testCases := []struct {
+		name                string
+		acceptEncoding      string
+		availableDictionary string
+		expected            string
+	}{
+		{
+			name:                "delta compressed",
+			acceptEncoding:      "gzip, br, zstd, dcb, dcz",
+			availableDictionary: availableDictionary,
+			expected:            "dcz",
+		},
+		{
+			name:                "unknown dictionary",
+			acceptEncoding:      "gzip, br, zstd, dcb, dcz",
+			availableDictionary: ":" + base64.StdEncoding.EncodeToString(unknownHash[:]) + ":",
+			expected:            "br",
+		},
+		{
+			name:                "dcz not accepted",
+			acceptEncoding:      "gzip, br",
+			availableDictionary: availableDictionary,
+			expected:            "br",
+		},
+		{
+			name:           "no dictionary",
+			acceptEncoding: "gzip, br, zstd, dcb, dcz",
+			expected:       "br",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/precompressed/app.js", nil)
+			r.URL.Path = "precompressed/app.js"
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.availableDictionary != "" {
+				r.Header.Set("Available-Dictionary", tt.availableDictionary)
+			}
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status to be 200 but got %d", w.Code)
+			}
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tt.expected {
+				t.Fatalf("expected Content-Encoding to be %q but got %q", tt.expected, encoding)
+			}
+			if useAsDictionary := w.Header().Get("Use-As-Dictionary"); useAsDictionary != `match="/static/*.js"` {
+				t.Errorf("unexpected Use-As-Dictionary header %s", useAsDictionary)
+			}
+			if vary := strings.Join(w.Header().Values("Vary"), ", "); !strings.Contains(vary, "Available-Dictionary") {
+				t.Errorf("expected Vary header to include Available-Dictionary but got %s", vary)
+			}
+
+			if tt.expected != dictionaryZstd {
+				return
+			}
+			if etag := w.Header().Get("ETag"); !strings.Contains(etag, "-dcz-") {
+				t.Errorf("expected ETag to identify the dictionary but got %s", etag)
+			}
+			body, err := decodeDictionaryZstd(w.Body.Bytes(), dict)
+			if err != nil {
+				t.Fatalf("unexpected error decoding response: %s", err)
+			}
+			if !bytes.Equal(body, original) {
+				t.Error("mismatched content")
+			}
+		})
+	}
+}
+
+// Decodes a 'dcz' response compressed against dict.
+func decodeDictionaryZstd(data, dict []byte) ([]byte, error) {
+	hash := sha256.Sum256(dict)
+	header := append([]byte(dczMagic), hash[:]...)
+	if !bytes.HasPrefix(data, header) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDictRaw(0, dict))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return dec.DecodeAll(data[len(header):], nil)
+}
+
+// flakyFS fails to open files until it's recovered.
+type flakyFS struct {
+	fs.FS
+	failing atomic.Bool
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	if f.failing.Load() {
+		return nil, fs.ErrPermission
+	}
+	return f.FS.Open(name)
+}
+
+func TestServerDictionariesUnavailable(t *testing.T) {
+	jsDictionaries := func(name string) string {
+		if path.Ext(name) == ".js" {
+			return "/*.js"
+		}
+		return ""
+	}
+	content := []byte(strings.Repeat("console.log('hello, world');\n", 100))
+	dict := []byte(strings.Repeat("console.log('hello');\n", 100))
+	hash := sha256.Sum256(dict)
+	availableDictionary := ":" + base64.StdEncoding.EncodeToString(hash[:]) + ":"
+
+	previous := &flakyFS{FS: fstest.MapFS{}}
+	previous.failing.Store(true)
+	fsys := fstest.MapFS{"app.js": {Data: content}}
+	s := New(fsys, WithDictionaries(jsDictionaries, os.DirFS("testdata/nonexistent"), previous))
+	s.dictionaries.refresh = 0
+
+	serve := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		r.URL.Path = "app.js"
+		r.Header.Set("Accept-Encoding", "gzip, br, dcz")
+		r.Header.Set("Available-Dictionary", availableDictionary)
+		s.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status to be 200 but got %d", w.Code)
+		}
+		return w.Header().Get("Content-Encoding")
+	}
+
+	if encoding := serve(); encoding != "br" {
+		t.Errorf("expected Content-Encoding to be br without readable dictionaries but got %q", encoding)
+	}
+
+	// The dictionary is found once its source recovers.
+	previous.FS = fstest.MapFS{"app.js": {Data: dict}}
+	previous.failing.Store(false)
+	if encoding := serve(); encoding != dictionaryZstd {
+		t.Errorf("expected Content-Encoding to be %s once the source recovers but got %q", dictionaryZstd, encoding)
+	}
+}
+
+func TestDictionariesIndexRefresh(t *testing.T) {
+	match := func(string) string { return "/*" }
+	dict := []byte("dictionary")
+	hash := sha256.Sum256(dict)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Available-Dictionary", ":"+base64.StdEncoding.EncodeToString(hash[:])+":")
+
+	fsys := fstest.MapFS{}
+	d := newDictionaries(match, []fs.FS{fsys})
+	if dict := d.lookup(r); dict != nil {
+		t.Fatal("expected no dictionary")
+	}
+
+	// Files added later aren't found until the index is stale.
+	fsys["dict.txt"] = &fstest.MapFile{Data: dict}
+	if dict := d.lookup(r); dict != nil {
+		t.Error("expected the index not to be rebuilt before the refresh interval")
+	}
+	d.refresh = 0
+	if dict := d.lookup(r); dict == nil {
+		t.Error("expected the index to be rebuilt")
+	}
+}
@@ -0,0 +1,110 @@
+package fileserver
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Digest algorithms supported in the Repr-Digest and Content-Digest headers (RFC 9530).
+const (
+	DigestSHA256 = "sha-256"
+	DigestSHA512 = "sha-512"
+)
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	DigestSHA256: sha256.New,
+	DigestSHA512: sha512.New,
+}
+
+// fileHashes holds the hashes of the contents of a file, calculated in a single read.
+type fileHashes struct {
+	// The ETag of the file, if calculated.
+	etag string
+	// Digests by algorithm, such as "sha-256".
+	digests map[string][]byte
+}
+
+// Returns the algorithms in want missing from h.
+func (h fileHashes) missingDigests(want []string) []string {
+	var missing []string
+	for _, algorithm := range want {
+		if _, ok := h.digests[algorithm]; !ok {
+			missing = append(missing, algorithm)
+		}
+	}
+	return missing
+}
+
+// Selects the digest algorithms to send, out of the ones enabled in the server, according to a
+// Want-Repr-Digest or Want-Content-Digest request header, such as 'sha-512=3, sha-256=10'.
+//
+// Without the header, all enabled algorithms are selected. Otherwise, only the enabled algorithm with
+// the highest preference is selected, and algorithms with a preference of 0 are never selected.
+func selectDigests(want string, enabled []string) []string {
+	if strings.TrimSpace(want) == "" {
+		return enabled
+	}
+
+	var (
+		best     string
+		bestPref int
+	)
+	for _, member := range strings.Split(want, ",") {
+		algorithm, value, _ := strings.Cut(member, "=")
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		pref, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || pref < 0 || pref > 10 {
+			continue
+		}
+		if pref > bestPref && slices.Contains(enabled, algorithm) {
+			best, bestPref = algorithm, pref
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return []string{best}
+}
+
+// Formats digests as a structured field dictionary, such as 'sha-256=:<base64>:'.
+func formatDigests(digests map[string][]byte, algorithms []string) string {
+	var members []string
+	for _, algorithm := range algorithms {
+		if digest, ok := digests[algorithm]; ok {
+			members = append(members, algorithm+"=:"+base64.StdEncoding.EncodeToString(digest)+":")
+		}
+	}
+	return strings.Join(members, ", ")
+}
+
+// Hashes data with the given algorithms.
+func digestBytes(data []byte, algorithms []string) map[string][]byte {
+	digests := make(map[string][]byte, len(algorithms))
+	for _, algorithm := range algorithms {
+		hasher := digestAlgorithms[algorithm]()
+		hasher.Write(data)
+		digests[algorithm] = hasher.Sum(nil)
+	}
+	return digests
+}
+
+// digestResponseWriter sets the Content-Digest header right before the response headers are written,
+// only if the response carries the whole representation. The content of partial responses, such as
+// 206 Partial Content, doesn't match the digest.
+type digestResponseWriter struct {
+	http.ResponseWriter
+	contentDigest string
+}
+
+func (d *digestResponseWriter) WriteHeader(status int) {
+	if status == http.StatusOK {
+		d.Header().Set("Content-Digest", d.contentDigest)
+	}
+	d.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,231 @@
+package fileserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestSelectDigests(t *testing.T) {
+	enabled := []string{DigestSHA256, DigestSHA512}
+
+	testCases := []struct {
+		want     string
+		expected []string
+	}{
+		{want: "", expected: enabled},
+		{want: "sha-256=1", expected: []string{DigestSHA256}},
+		{want: "sha-256=3, sha-512=10", expected: []string{DigestSHA512}},
+		{want: "SHA-512=5", expected: []string{DigestSHA512}},
+		{want: "sha-256=0", expected: nil},
+		{want: "md5=10, sha-256=2", expected: []string{DigestSHA256}},
+		{want: "md5=10", expected: nil},
+		{want: "sha-256=11", expected: nil},
+		{want: "sha-256", expected: nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.want, func(t *testing.T) {
+			result := selectDigests(tt.want, enabled)
+			if !slices.Equal(result, tt.expected) {
+				t.Errorf("expected algorithms to be %v but got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFormatDigests(t *testing.T) {
+	digests := digestBytes([]byte("hello"), []string{DigestSHA256, DigestSHA512})
+
+	sha256Sum := sha256.Sum256([]byte("hello"))
+	sha512Sum := sha512.Sum512([]byte("hello"))
+	expected := "sha-256=:" + base64.StdEncoding.EncodeToString(sha256Sum[:]) + ":, " +
+		"sha-512=:" + base64.StdEncoding.EncodeToString(sha512Sum[:]) + ":"
+
+	result := formatDigests(digests, []string{DigestSHA256, DigestSHA512})
+	if result != expected {
+		t.Errorf("expected digests to be %q but got %q", expected, result)
+	}
+}
+
+func TestServerDigests(t *testing.T) {
+	h := New(
+		os.DirFS("testdata"),
+		WithDigests(DigestSHA256),
+		WithCompressionLimits(0, 15<<20),
+	)
+	srv := httptest.NewServer(http.StripPrefix("/", h))
+
+	sidecar, err := os.ReadFile("testdata/precompressed/app.js.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile("testdata/precompressed/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha256Digest := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+	}
+
+	testCases := []struct {
+		name          string
+		path          string
+		header        http.Header
+		status        int
+		reprDigest    string
+		contentDigest string
+	}{
+		{
+			name:          "identity",
+			path:          "/precompressed/app.js",
+			status:        http.StatusOK,
+			reprDigest:    sha256Digest(original),
+			contentDigest: sha256Digest(original),
+		},
+		{
+			name:          "precompressed",
+			path:          "/precompressed/app.js",
+			header:        http.Header{"Accept-Encoding": {"gzip"}},
+			status:        http.StatusOK,
+			reprDigest:    sha256Digest(sidecar),
+			contentDigest: sha256Digest(sidecar),
+		},
+		{
+			name:          "unwanted algorithm",
+			path:          "/precompressed/app.js",
+			header:        http.Header{"Want-Repr-Digest": {"sha-512=10"}},
+			status:        http.StatusOK,
+			reprDigest:    "",
+			contentDigest: sha256Digest(original),
+		},
+		{
+			name:          "partial content",
+			path:          "/precompressed/app.js",
+			header:        http.Header{"Range": {"bytes=0-9"}},
+			status:        http.StatusPartialContent,
+			reprDigest:    sha256Digest(original),
+			contentDigest: "",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := srv.Client()
+			client.Transport = &http.Transport{
+				DisableCompression: true,
+			}
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %s", err)
+			}
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error making request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, res.StatusCode)
+			}
+			if digest := res.Header.Get("Repr-Digest"); digest != tt.reprDigest {
+				t.Errorf("expected Repr-Digest to be %q but got %q", tt.reprDigest, digest)
+			}
+			if digest := res.Header.Get("Content-Digest"); digest != tt.contentDigest {
+				t.Errorf("expected Content-Digest to be %q but got %q", tt.contentDigest, digest)
+			}
+		})
+	}
+
+	t.Run("compressed", func(t *testing.T) {
+		h := New(
+			os.DirFS("testdata"),
+			WithPrecompressed(false),
+			WithDigests(DigestSHA256),
+			WithCompressionLimits(0, 15<<20),
+		)
+		srv := httptest.NewServer(http.StripPrefix("/", h))
+
+		client := srv.Client()
+		client.Transport = &http.Transport{
+			DisableCompression: true,
+		}
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/precompressed/app.js", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %s", err)
+		}
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error making request: %s", err)
+		}
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("unexpected error reading response: %s", err)
+		}
+		if digest := res.Header.Get("Repr-Digest"); digest != sha256Digest(body) {
+			t.Errorf("expected Repr-Digest to be %q but got %q", sha256Digest(body), digest)
+		}
+		if _, err := gzip.NewReader(bytes.NewReader(body)); err != nil {
+			t.Errorf("unexpected error creating gzip reader: %s", err)
+		}
+	})
+}
+
+func TestServerDigestsCompressionFallback(t *testing.T) {
+	// Compression is rejected by the gain check after reading the file, so the identity digest must
+	// come from the first read.
+	h := New(
+		os.DirFS("testdata"),
+		WithPrecompressed(false),
+		WithETagCache(0),
+		WithDigests(DigestSHA256),
+		WithMinCompressionGain(0.99),
+		WithCompressionLimits(0, 15<<20),
+	)
+
+	original, err := os.ReadFile("testdata/precompressed/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(original)
+	expected := "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/precompressed/app.js", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	http.StripPrefix("/", h).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status to be %d but got %d", http.StatusOK, w.Code)
+	}
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected Content-Encoding to be empty but got %q", encoding)
+	}
+	if digest := w.Header().Get("Repr-Digest"); digest != expected {
+		t.Errorf("expected Repr-Digest to be %q but got %q", expected, digest)
+	}
+	if digest := w.Header().Get("Content-Digest"); digest != expected {
+		t.Errorf("expected Content-Digest to be %q but got %q", expected, digest)
+	}
+	if !bytes.Equal(w.Body.Bytes(), original) {
+		t.Error("mismatched content")
+	}
+}
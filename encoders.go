@@ -0,0 +1,53 @@
+package fileserver
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Function used to compress a response body on-the-fly with a content-coding. The returned writer
+// must compress everything written to it into w and flush any remaining data when closed.
+type EncoderFunc func(w io.Writer) (io.WriteCloser, error)
+
+// Compresses data using gzip with the default compression level.
+func GzipEncoder(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// Compresses data using brotli with the default compression level.
+func BrotliEncoder(w io.Writer) (io.WriteCloser, error) {
+	return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+}
+
+// Compresses data using zstd with the default compression level.
+func ZstdEncoder(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+}
+
+// Returns the built-in encoders, in order of preference.
+func defaultEncoders() ([]string, map[string]EncoderFunc) {
+	encodings := []string{"br", "zstd", "gzip"}
+	encoders := map[string]EncoderFunc{
+		"br":   BrotliEncoder,
+		"zstd": ZstdEncoder,
+		"gzip": GzipEncoder,
+	}
+	return encodings, encoders
+}
+
+// Compresses all of r into w using the given encoder.
+func encode(w io.Writer, r io.Reader, encoderFn EncoderFunc) error {
+	enc, err := encoderFn(w)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(enc, r); err != nil {
+		enc.Close()
+		return err
+	}
+	// Closing the encoder flushes the compressed data to w.
+	return enc.Close()
+}
@@ -0,0 +1,74 @@
+package fileserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestEncoders(t *testing.T) {
+	testCases := []struct {
+		name      string
+		encoderFn EncoderFunc
+		newReader func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			name:      "gzip",
+			encoderFn: GzipEncoder,
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			name:      "br",
+			encoderFn: BrotliEncoder,
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return brotli.NewReader(r), nil
+			},
+		},
+		{
+			name:      "zstd",
+			encoderFn: ZstdEncoder,
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+	}
+
+	content := strings.Repeat("Hello, world! ", 1024)
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			if err := encode(buf, strings.NewReader(content), tt.encoderFn); err != nil {
+				t.Fatalf("unexpected error encoding content: %s", err)
+			}
+			if buf.Len() >= len(content) {
+				t.Errorf("expected encoded content to be smaller than %d bytes but got %d", len(content), buf.Len())
+			}
+
+			r, err := tt.newReader(buf)
+			if err != nil {
+				t.Fatalf("unexpected error creating reader: %s", err)
+			}
+			decoded, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error decoding content: %s", err)
+			}
+			if string(decoded) != content {
+				t.Error("expected decoded content to match the original")
+			}
+		})
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	err := encode(io.Discard, &errorReader{}, GzipEncoder)
+	if err == nil {
+		t.Error("expected error reading content")
+	}
+}
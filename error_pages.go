@@ -0,0 +1,138 @@
+package fileserver
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"sync"
+)
+
+// The size of the compression cache of error pages served from a separate [fs.FS], 1mb.
+const errorPagesCacheSize = 1 << 20
+
+// Headers describing the representation of the file that failed to be served, which don't apply to
+// error responses.
+var representationHeaders = []string{
+	"Accept-Ranges",
+	"Cache-Control",
+	"Cache-Tag",
+	"CDN-Cache-Control",
+	"Content-Digest",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"ETag",
+	"Expires",
+	"Last-Modified",
+	"Repr-Digest",
+	"Surrogate-Control",
+	"Surrogate-Key",
+	"Use-As-Dictionary",
+}
+
+// Conditional and range request headers, which would make the error page be served as 304 Not Modified
+// or 206 Partial Content.
+var errorPageRequestHeaders = []string{
+	"If-Match",
+	"If-Modified-Since",
+	"If-None-Match",
+	"If-Range",
+	"If-Unmodified-Since",
+	"Range",
+}
+
+// errorPageKey is the context key of the error that caused an error page to be served.
+type errorPageKey struct{}
+
+// errorPages serves error pages from an [fs.FS], see [WithErrorPages].
+type errorPages struct {
+	// The server the error pages replace the error handler of.
+	server *Server
+	fsys   fs.FS
+	pages  map[int]string
+
+	once sync.Once
+	// The server of the error pages, which is configured like the original one, but never calls back
+	// into the error pages.
+	pageServer *Server
+}
+
+// Serves the error page of the status code of err, or falls back to the default error handler if there
+// isn't one.
+func (e *errorPages) serve(w http.ResponseWriter, r *http.Request, err error) {
+	status, _ := errorStatus(err)
+	page, ok := e.pages[status]
+	if !ok {
+		defaultErrorHandler(w, r, err)
+		return
+	}
+	e.once.Do(e.init)
+
+	for _, header := range representationHeaders {
+		w.Header().Del(header)
+	}
+
+	pr := r.Clone(context.WithValue(r.Context(), errorPageKey{}, err))
+	if pr.Method != http.MethodHead {
+		pr.Method = http.MethodGet
+	}
+	pr.URL.Path = page
+	pr.URL.RawPath = ""
+	pr.URL.RawQuery = ""
+	for _, header := range errorPageRequestHeaders {
+		pr.Header.Del(header)
+	}
+
+	e.pageServer.ServeHTTP(&errorPageWriter{ResponseWriter: w, status: status}, pr)
+}
+
+// Creates the server of the error pages from the original server, once all of its options are applied.
+func (e *errorPages) init() {
+	page := *e.server
+	page.fs = e.fsys
+	if e.fsys == nil {
+		page.fs = e.server.fs
+	} else {
+		page.cache = newCompressionCache(errorPagesCacheSize)
+	}
+	page.etagFn = nil
+	page.fileETagFn = nil
+	page.manifest = nil
+	page.digests = nil
+	page.dictionaries = nil
+	page.surrogateKeysFn = nil
+	page.cacheHeadersFn = nil
+	page.cacheControlFn = func(*http.Request) string { return "no-store" }
+	page.listing = false
+	page.archives = false
+	page.deny = nil
+	// If the error page can't be served, such as when it's missing, the original error is handled
+	// as plain text instead.
+	page.errHandler = func(w http.ResponseWriter, r *http.Request, _ error) {
+		err, _ := r.Context().Value(errorPageKey{}).(error)
+		defaultErrorHandler(w, r, err)
+	}
+	e.pageServer = &page
+}
+
+// errorPageWriter writes the status code of the error, instead of the one of the error page.
+type errorPageWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (e *errorPageWriter) WriteHeader(int) {
+	if e.wroteHeader {
+		return
+	}
+	e.wroteHeader = true
+	e.ResponseWriter.WriteHeader(e.status)
+}
+
+func (e *errorPageWriter) Write(b []byte) (int, error) {
+	e.WriteHeader(e.status)
+	return e.ResponseWriter.Write(b)
+}
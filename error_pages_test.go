@@ -0,0 +1,158 @@
+package fileserver
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithErrorPages(t *testing.T) {
+	notFound := "<!doctype html><title>Not Found</title>" + strings.Repeat("<p>Nothing to see here</p>", 100)
+	site := fstest.MapFS{
+		"file.txt":  {Data: []byte("file")},
+		"404.html":  {Data: []byte(notFound)},
+		"405.html":  {Data: []byte("method not allowed page")},
+		".env":      {Data: []byte("SECRET=1")},
+		"dir/a.txt": {Data: []byte("a")},
+	}
+	pages := map[int]string{
+		http.StatusNotFound:            "404.html",
+		http.StatusMethodNotAllowed:    "405.html",
+		http.StatusInternalServerError: "500.html",
+	}
+
+	testCases := []struct {
+		name         string
+		opts         []ServerOptFn
+		method       string
+		target       string
+		header       http.Header
+		status       int
+		contentType  string
+		cacheControl string
+		encoding     string
+		body         string
+	}{
+		{
+			name:         "not found",
+			target:       "/missing.txt",
+			status:       http.StatusNotFound,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			body:         notFound,
+		},
+		{
+			name:         "compressed",
+			target:       "/missing.txt",
+			header:       http.Header{"Accept-Encoding": {"gzip"}},
+			status:       http.StatusNotFound,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			encoding:     "gzip",
+			body:         notFound,
+		},
+		{
+			name:         "conditional request",
+			target:       "/missing.txt",
+			header:       http.Header{"If-None-Match": {"*"}, "Range": {"bytes=0-1"}},
+			status:       http.StatusNotFound,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			body:         notFound,
+		},
+		{
+			name:         "invalid method",
+			method:       http.MethodPost,
+			target:       "/file.txt",
+			status:       http.StatusMethodNotAllowed,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			body:         "method not allowed page",
+		},
+		{
+			name:        "unmapped status",
+			opts:        []ServerOptFn{WithArchives(true)},
+			target:      "/dir/?archive=rar",
+			status:      http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8",
+			body:        "invalid query parameter\n",
+		},
+		{
+			name:        "missing page",
+			opts:        []ServerOptFn{WithErrorPages(fstest.MapFS{}, pages)},
+			target:      "/missing.txt",
+			status:      http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+			body:        "file not found\n",
+		},
+		{
+			name:         "separate fs",
+			opts:         []ServerOptFn{WithErrorPages(fstest.MapFS{"errors/404.html": {Data: []byte("gone")}}, map[int]string{404: "errors/404.html"})},
+			target:       "/missing.txt",
+			status:       http.StatusNotFound,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			body:         "gone",
+		},
+		{
+			name:         "denied page",
+			opts:         []ServerOptFn{WithDeny("*.html")},
+			target:       "/missing.txt",
+			status:       http.StatusNotFound,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			body:         notFound,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]ServerOptFn{WithErrorPages(nil, pages), WithCompressionLimits(0, 1<<20)}, tt.opts...)
+			h := New(site, opts...)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(method, tt.target, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			http.StripPrefix("/", h).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("expected Content-Type to be %q but got %q", tt.contentType, contentType)
+			}
+			if cacheControl := w.Header().Get("Cache-Control"); cacheControl != tt.cacheControl {
+				t.Errorf("expected Cache-Control to be %q but got %q", tt.cacheControl, cacheControl)
+			}
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tt.encoding {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.encoding, encoding)
+			}
+
+			var body io.Reader = w.Body
+			if tt.encoding == "gzip" {
+				gzr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("unexpected error creating gzip reader: %s", err)
+				}
+				body = gzr
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("unexpected error reading response: %s", err)
+			}
+			if string(b) != tt.body {
+				t.Errorf("expected body to be %q but got %q", tt.body, b)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"strings"
 )
 
 var (
@@ -15,19 +16,132 @@ var (
 	// This server only supports GET and HEAD requests. For any other method, the server's [ErrorHandlerFunc] is
 	// called with this error.
 	ErrInvalidMethod = errors.New("fileserver: invalid http method")
+	// None of the content-codings available for the file is acceptable according to the request's
+	// Accept-Encoding header, for example when 'identity;q=0' is sent for an uncompressed file.
+	ErrNotAcceptable = errors.New("fileserver: no acceptable content encoding")
+	// A query parameter of the request is invalid, such as the cursor of a JSON dir listing.
+	ErrInvalidQuery = errors.New("fileserver: invalid query parameter")
+	// The requested dir archive exceeds the limits of the server, see [WithArchiveLimits].
+	ErrArchiveTooLarge = errors.New("fileserver: archive too large")
+
+	// The file opened from the [fs.FS] doesn't implement [io.Seeker], which is required to serve it.
+	errNotSeeker  = errors.New("file does not implement io.Seeker")
+	errUnreadable = errors.New("file contents aren't available")
 )
 
-type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+// Error is the error the server passes to its [ErrorHandlerFunc]. Its cause can be matched with [errors.Is],
+// such as [ErrFileNotFound] or [fs.ErrPermission].
+type Error struct {
+	// The status code of the response to the error.
+	StatusCode int
+	// The operation that failed, such as "open", "stat", "etag", "compress" or "seek".
+	Op string
+	// The slash-separated path of the file the operation failed on.
+	Path string
+	// The cause of the error.
+	Err error
+}
 
-func defaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return "fileserver: " + e.Op + " " + e.Path + ": " + http.StatusText(e.StatusCode)
+	}
+	return "fileserver: " + e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Creates an [Error] of a failed file system operation, with a status code derived from err:
+// 404 for [fs.ErrNotExist], 400 for [fs.ErrInvalid], 403 for [fs.ErrPermission] and 500 otherwise.
+func fsError(op, name string, err error) *Error {
 	switch {
-	case errors.Is(err, ErrFileNotFound):
-		http.Error(w, "file not found", http.StatusNotFound)
-	case errors.Is(err, ErrInvalidPath):
-		http.Error(w, "invalid file path", http.StatusBadRequest)
-	case errors.Is(err, ErrInvalidMethod):
-		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+	case errors.Is(err, fs.ErrNotExist):
+		return &Error{StatusCode: http.StatusNotFound, Op: op, Path: name, Err: &causeError{ErrFileNotFound, err}}
+	case errors.Is(err, fs.ErrInvalid):
+		return &Error{StatusCode: http.StatusBadRequest, Op: op, Path: name, Err: &causeError{ErrInvalidPath, err}}
+	case errors.Is(err, fs.ErrPermission):
+		return &Error{StatusCode: http.StatusForbidden, Op: op, Path: name, Err: err}
 	default:
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return &Error{StatusCode: http.StatusInternalServerError, Op: op, Path: name, Err: err}
+	}
+}
+
+// causeError is a sentinel error, such as [ErrFileNotFound], along with the error that caused it, so
+// both can be matched with [errors.Is] and [errors.As].
+type causeError struct {
+	sentinel error
+	cause    error
+}
+
+func (e *causeError) Error() string {
+	msg := strings.TrimPrefix(e.sentinel.Error(), "fileserver: ")
+	if known, ok := lookupError(e.sentinel); ok {
+		msg = known.message
+	}
+
+	// The operation and path are already part of the message of the [Error].
+	cause := e.cause
+	var pathErr *fs.PathError
+	if errors.As(cause, &pathErr) {
+		cause = pathErr.Err
+	}
+	return msg + ": " + cause.Error()
+}
+
+func (e *causeError) Unwrap() []error {
+	return []error{e.sentinel, e.cause}
+}
+
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+func defaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := errorStatus(err)
+	http.Error(w, message, status)
+}
+
+// A known error of the server, with its response.
+type knownError struct {
+	err error
+	// The name of the error variable, used in its problem type URI.
+	name    string
+	status  int
+	message string
+}
+
+var knownErrors = []knownError{
+	{err: ErrFileNotFound, name: "ErrFileNotFound", status: http.StatusNotFound, message: "file not found"},
+	{err: ErrInvalidPath, name: "ErrInvalidPath", status: http.StatusBadRequest, message: "invalid file path"},
+	{err: ErrInvalidMethod, name: "ErrInvalidMethod", status: http.StatusMethodNotAllowed, message: "only GET is supported"},
+	{err: ErrInvalidQuery, name: "ErrInvalidQuery", status: http.StatusBadRequest, message: "invalid query parameter"},
+	{err: ErrArchiveTooLarge, name: "ErrArchiveTooLarge", status: http.StatusForbidden, message: "archive too large"},
+	{err: ErrNotAcceptable, name: "ErrNotAcceptable", status: http.StatusNotAcceptable, message: "no acceptable content encoding"},
+}
+
+// Returns the known error err wraps, if any.
+func lookupError(err error) (knownError, bool) {
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return known, true
+		}
+	}
+	return knownError{}, false
+}
+
+// Returns the status code and the plain text message of the response to err. The status code of an
+// [Error] takes precedence over the one of the known error it wraps.
+func errorStatus(err error) (int, string) {
+	status, message := http.StatusInternalServerError, ""
+	if known, ok := lookupError(err); ok {
+		status, message = known.status, known.message
+	}
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode != 0 && e.StatusCode != status {
+		status, message = e.StatusCode, ""
+	}
+	if message == "" {
+		message = http.StatusText(status)
 	}
+	return status, message
 }
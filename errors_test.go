@@ -0,0 +1,190 @@
+package fileserver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("disk on fire")
+	err := error(&Error{StatusCode: http.StatusInternalServerError, Op: "open", Path: "file.txt", Err: cause})
+
+	if msg := err.Error(); msg != "fileserver: open file.txt: disk on fire" {
+		t.Errorf("expected message to be %q but got %q", "fileserver: open file.txt: disk on fire", msg)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected error to wrap its cause")
+	}
+
+	noCause := &Error{StatusCode: http.StatusForbidden, Op: "archive", Path: "docs"}
+	if msg := noCause.Error(); msg != "fileserver: archive docs: Forbidden" {
+		t.Errorf("expected message to be %q but got %q", "fileserver: archive docs: Forbidden", msg)
+	}
+
+	notFound := fsError("open", "file.txt", fs.ErrNotExist)
+	if !errors.Is(notFound, ErrFileNotFound) || notFound.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected error %+v", notFound)
+	}
+
+	_, openErr := fstest.MapFS{}.Open("missing.txt")
+	missing := fsError("open", "missing.txt", openErr)
+	var pathErr *fs.PathError
+	if !errors.Is(missing, ErrFileNotFound) || !errors.As(missing, &pathErr) {
+		t.Errorf("expected error to wrap both %v and the %T cause", ErrFileNotFound, pathErr)
+	}
+	if msg := missing.Error(); msg != "fileserver: open missing.txt: file not found: file does not exist" {
+		t.Errorf("expected message to be %q but got %q", "fileserver: open missing.txt: file not found: file does not exist", msg)
+	}
+
+	invalid := fsError("open", "../file.txt", &fs.PathError{Op: "open", Path: "../file.txt", Err: fmt.Errorf("%w: bad name", fs.ErrInvalid)})
+	if !errors.Is(invalid, ErrInvalidPath) || !errors.As(invalid, &pathErr) {
+		t.Errorf("expected error to wrap both %v and the %T cause", ErrInvalidPath, pathErr)
+	}
+	if msg := invalid.Error(); msg != "fileserver: open ../file.txt: invalid file path: invalid argument: bad name" {
+		t.Errorf("expected message to be %q but got %q", "fileserver: open ../file.txt: invalid file path: invalid argument: bad name", msg)
+	}
+	forbidden := fsError("open", "file.txt", fs.ErrPermission)
+	if !errors.Is(forbidden, fs.ErrPermission) || forbidden.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected error %+v", forbidden)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	testCases := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{name: "sentinel", err: ErrFileNotFound, status: http.StatusNotFound, message: "file not found"},
+		{name: "unknown", err: errors.New("boom"), status: http.StatusInternalServerError, message: "Internal Server Error"},
+		{
+			name:    "typed",
+			err:     &Error{StatusCode: http.StatusForbidden, Op: "open", Path: "file.txt", Err: fs.ErrPermission},
+			status:  http.StatusForbidden,
+			message: "Forbidden",
+		},
+		{
+			name:    "typed sentinel",
+			err:     &Error{StatusCode: http.StatusNotFound, Op: "open", Path: "file.txt", Err: ErrFileNotFound},
+			status:  http.StatusNotFound,
+			message: "file not found",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := errorStatus(tt.err)
+			if status != tt.status {
+				t.Errorf("expected status to be %d but got %d", tt.status, status)
+			}
+			if message != tt.message {
+				t.Errorf("expected message to be %q but got %q", tt.message, message)
+			}
+		})
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   []ServerOptFn
+		method string
+		path   string
+		header http.Header
+		status int
+		op     string
+		target error
+	}{
+		{
+			name:   "not found",
+			path:   "/missing.txt",
+			status: http.StatusNotFound,
+			op:     "open",
+			target: ErrFileNotFound,
+		},
+		{
+			name:   "invalid path",
+			path:   "/../file.txt",
+			status: http.StatusBadRequest,
+			op:     "open",
+			target: ErrInvalidPath,
+		},
+		{
+			name:   "invalid method",
+			method: http.MethodPost,
+			path:   "/file.txt",
+			status: http.StatusMethodNotAllowed,
+			op:     "method",
+			target: ErrInvalidMethod,
+		},
+		{
+			name:   "missing index",
+			path:   "/subdir/",
+			status: http.StatusNotFound,
+			op:     "index",
+			target: ErrFileNotFound,
+		},
+		{
+			name:   "not acceptable",
+			path:   "/file.txt",
+			header: http.Header{"Accept-Encoding": {"identity;q=0"}},
+			status: http.StatusNotAcceptable,
+			op:     "negotiate",
+			target: ErrNotAcceptable,
+		},
+		{
+			name:   "etag",
+			opts:   []ServerOptFn{WithETagFunc(errorETagFunc)},
+			path:   "/file.txt",
+			status: http.StatusInternalServerError,
+			op:     "etag",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled error
+			opts := append([]ServerOptFn{WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				handled = err
+				defaultErrorHandler(w, r, err)
+			})}, tt.opts...)
+			h := New(os.DirFS("testdata"), opts...)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(method, tt.path, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			r.URL.Path = tt.path[1:]
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+			var e *Error
+			if !errors.As(handled, &e) {
+				t.Fatalf("expected error to be an *Error but got %T", handled)
+			}
+			if e.StatusCode != tt.status {
+				t.Errorf("expected error status to be %d but got %d", tt.status, e.StatusCode)
+			}
+			if e.Op != tt.op {
+				t.Errorf("expected error op to be %q but got %q", tt.op, e.Op)
+			}
+			if tt.target != nil && !errors.Is(handled, tt.target) {
+				t.Errorf("expected error to wrap %v", tt.target)
+			}
+		})
+	}
+}
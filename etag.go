@@ -2,23 +2,155 @@ package fileserver
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
+	"hash"
+	"hash/fnv"
 	"io"
+	"io/fs"
+	"net/http"
 	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 // Function used to calculate the entity tag (ETag) value for the file.
 // By default, a hex encoded md5 hash of the file is used.
 type ETagFunc func(r io.Reader) (string, error)
 
+// Function used to calculate the entity tag (ETag) value for the file, given its path and [fs.FileInfo].
+//
+// Unlike [ETagFunc], it allows ETags derived from the file metadata, such as [WeakETag], in which case
+// r doesn't need to be read at all.
+type FileETagFunc func(name string, info fs.FileInfo, r io.Reader) (string, error)
+
 // Calculates the entity tag by md5 hashing r and quoting the hex encoded result.
 func calculateETag(r io.Reader) (string, error) {
-	hasher := md5.New()
+	return hashETag(md5.New(), r)
+}
+
+// Calculates the entity tag by md5 hashing r and quoting the hex encoded result. This is the default.
+func MD5ETag(r io.Reader) (string, error) {
+	return calculateETag(r)
+}
+
+// Calculates the entity tag by SHA-256 hashing r and quoting the hex encoded result.
+func SHA256ETag(r io.Reader) (string, error) {
+	return hashETag(sha256.New(), r)
+}
+
+// Calculates the entity tag by hashing r with the 64-bit xxHash algorithm and quoting the hex
+// encoded result. It's much faster than cryptographic hashes, while still suitable for detecting
+// changes.
+func XXHashETag(r io.Reader) (string, error) {
+	return hashETag(xxhash.New(), r)
+}
+
+// Calculates the entity tag by hashing r with the 64-bit FNV-1a algorithm and quoting the hex
+// encoded result.
+func FNVETag(r io.Reader) (string, error) {
+	return hashETag(fnv.New64a(), r)
+}
+
+// Calculates a weak entity tag from the file size and modification time, such as 'W/"1a2b-18c3d4e5f6a7b8c9"',
+// without reading the file.
+//
+// Weak ETags are cheap, but two versions of a file with the same size and modification time get the same
+// ETag, and Range requests with an If-Range header are always answered with the full file.
+//
+// Files without a modification time, such as the ones in an [embed.FS], would all share the same ETag
+// for a given size, so their contents are hashed with [XXHashETag] instead.
+func WeakETag(_ string, info fs.FileInfo, r io.Reader) (string, error) {
+	if isZeroTime(info.ModTime()) {
+		etag, err := XXHashETag(r)
+		if err != nil {
+			return "", err
+		}
+		return "W/" + etag, nil
+	}
+	size := strconv.FormatInt(info.Size(), 16)
+	modTime := strconv.FormatInt(info.ModTime().UnixNano(), 16)
+	return `W/"` + size + "-" + modTime + `"`, nil
+}
+
+// Hashes r with hasher and quotes the hex encoded result.
+func hashETag(hasher hash.Hash, r io.Reader) (string, error) {
 	_, err := io.Copy(hasher, r)
 	if err != nil {
 		return "", err
 	}
-	hash := hasher.Sum(nil)
-	value := hex.EncodeToString(hash)
+	sum := hasher.Sum(nil)
+	value := hex.EncodeToString(sum)
 	return strconv.Quote(value), nil
 }
+
+// Returns the ETag of the representation of a file encoded with encoding, derived from the ETag
+// of its contents. For example, '"<hash>"' becomes '"<hash>-gzip"'.
+//
+// Giving each encoding its own ETag prevents caches from mixing byte ranges or validators of
+// different representations.
+func encodedETag(etag, encoding string) string {
+	if etag == "" || encoding == identity {
+		return etag
+	}
+	if strings.HasSuffix(etag, `"`) && len(etag) > 1 {
+		return etag[:len(etag)-1] + "-" + encoding + `"`
+	}
+	return etag + "-" + encoding
+}
+
+// Returns the encoding of the representation identified by tag, if it's the ETag of any
+// representation of the contents identified by etag.
+func representationEncoding(tag, etag string, encodings []string) (string, bool) {
+	if tag == etag {
+		return identity, true
+	}
+	for _, encoding := range encodings {
+		if tag == encodedETag(etag, encoding) {
+			return encoding, true
+		}
+	}
+	return "", false
+}
+
+// Returns the encoding of the representation named by the If-Range header of a Range request,
+// if it's a strong ETag of one of the representations of the contents identified by etag.
+func ifRangeEncoding(r *http.Request, etag string, encodings []string) (string, bool) {
+	ifRange := strings.TrimSpace(r.Header.Get("If-Range"))
+	if etag == "" || ifRange == "" || r.Header.Get("Range") == "" || strings.HasPrefix(ifRange, "W/") {
+		return "", false
+	}
+	return representationEncoding(ifRange, strings.TrimPrefix(etag, "W/"), encodings)
+}
+
+// Returns r with the ETags of the If-None-Match header that identify any representation of the
+// contents identified by etag replaced by current, the ETag of the representation being served.
+// Since If-None-Match uses the weak comparison, the 'W/' prefix is ignored. If no replacement is
+// needed, r is returned unchanged.
+func withNormalizedIfNoneMatch(r *http.Request, etag, current string, encodings []string) *http.Request {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return r
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	tags := strings.Split(ifNoneMatch, ",")
+	changed := false
+	for i, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if _, ok := representationEncoding(tag, etag, encodings); ok && tag != strings.TrimPrefix(current, "W/") {
+			tags[i] = current
+			changed = true
+		}
+	}
+	if !changed {
+		return r
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = r.Header.Clone()
+	r2.Header.Set("If-None-Match", strings.Join(tags, ","))
+	return r2
+}
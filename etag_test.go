@@ -3,8 +3,13 @@ package fileserver
 import (
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 type errorReader struct{}
@@ -52,3 +57,205 @@ func TestCalculateETag(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodedETag(t *testing.T) {
+	tests := []struct {
+		name     string
+		etag     string
+		encoding string
+		expected string
+	}{
+		{
+			name:     "strong",
+			etag:     `"abc"`,
+			encoding: "gzip",
+			expected: `"abc-gzip"`,
+		},
+		{
+			name:     "weak",
+			etag:     `W/"abc"`,
+			encoding: "br",
+			expected: `W/"abc-br"`,
+		},
+		{
+			name:     "identity",
+			etag:     `"abc"`,
+			encoding: identity,
+			expected: `"abc"`,
+		},
+		{
+			name:     "unquoted",
+			etag:     "abc",
+			encoding: "zstd",
+			expected: "abc-zstd",
+		},
+		{
+			name:     "empty",
+			etag:     "",
+			encoding: "gzip",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := encodedETag(tt.etag, tt.encoding)
+			if result != tt.expected {
+				t.Errorf("expected etag to be %s but got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestWithNormalizedIfNoneMatch(t *testing.T) {
+	encodings := []string{"br", "gzip"}
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		current     string
+		expected    string
+	}{
+		{
+			name:        "identity form",
+			ifNoneMatch: `"abc"`,
+			current:     `"abc-gzip"`,
+			expected:    `"abc-gzip"`,
+		},
+		{
+			name:        "other encoding",
+			ifNoneMatch: `"abc-br"`,
+			current:     `"abc-gzip"`,
+			expected:    `"abc-gzip"`,
+		},
+		{
+			name:        "weak",
+			ifNoneMatch: `W/"abc-br"`,
+			current:     `"abc"`,
+			expected:    `"abc"`,
+		},
+		{
+			name:        "list",
+			ifNoneMatch: `"xyz", "abc"`,
+			current:     `"abc-br"`,
+			expected:    `"xyz","abc-br"`,
+		},
+		{
+			name:        "unrelated",
+			ifNoneMatch: `"xyz"`,
+			current:     `"abc-br"`,
+			expected:    `"xyz"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			result := withNormalizedIfNoneMatch(r, `"abc"`, tt.current, encodings)
+			if ifNoneMatch := result.Header.Get("If-None-Match"); ifNoneMatch != tt.expected {
+				t.Errorf("expected If-None-Match to be %s but got %s", tt.expected, ifNoneMatch)
+			}
+			if r.Header.Get("If-None-Match") != tt.ifNoneMatch {
+				t.Error("expected the original request not to be modified")
+			}
+		})
+	}
+}
+
+func TestETagFuncs(t *testing.T) {
+	tests := []struct {
+		name         string
+		etagFn       ETagFunc
+		expectedETag string
+	}{
+		{
+			name:         "md5",
+			etagFn:       MD5ETag,
+			expectedETag: `"6cd3556deb0da54bca060b4c39479839"`,
+		},
+		{
+			name:         "sha256",
+			etagFn:       SHA256ETag,
+			expectedETag: `"315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3"`,
+		},
+		{
+			name:         "xxhash",
+			etagFn:       XXHashETag,
+			expectedETag: `"f58336a78b6f9476"`,
+		},
+		{
+			name:         "fnv",
+			etagFn:       FNVETag,
+			expectedETag: `"38d1334144987bf4"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etag, err := tt.etagFn(strings.NewReader("Hello, world!"))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if etag != tt.expectedETag {
+				t.Errorf("expected etag to be %v but got %v", tt.expectedETag, etag)
+			}
+
+			if _, err := tt.etagFn(&errorReader{}); err == nil {
+				t.Error("expected error reading content")
+			}
+		})
+	}
+}
+
+func TestWeakETag(t *testing.T) {
+	modTime := time.Unix(1700000000, 0)
+	info := fakeFileInfo{size: 4096, modTime: modTime}
+
+	// The reader must not be used.
+	etag, err := WeakETag("file.txt", info, &errorReader{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := `W/"1000-` + strconv.FormatInt(modTime.UnixNano(), 16) + `"`
+	if etag != expected {
+		t.Errorf("expected etag to be %s but got %s", expected, etag)
+	}
+
+	changed, _ := WeakETag("file.txt", fakeFileInfo{size: 4096, modTime: modTime.Add(time.Second)}, nil)
+	if changed == etag {
+		t.Error("expected etag to change with the modification time")
+	}
+}
+
+func TestWeakETagZeroModTime(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": &fstest.MapFile{Data: []byte("aaaa")},
+		"b.txt": &fstest.MapFile{Data: []byte("bbbb")},
+	}
+
+	etags := make(map[string]string)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		etag, err := WeakETag(name, info, f)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.HasPrefix(etag, "W/") {
+			t.Errorf("expected etag to be weak but got %s", etag)
+		}
+		etags[name] = etag
+	}
+
+	if etags["a.txt"] == etags["b.txt"] {
+		t.Errorf("expected files with the same size and no modification time to have different etags but got %s", etags["a.txt"])
+	}
+}
@@ -1,3 +1,12 @@
 module github.com/ffss92/fileserver
 
-go 1.18
+go 1.21
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.17.11
+)
+
+require golang.org/x/sync v0.10.0
+
+require github.com/cespare/xxhash/v2 v2.3.0
@@ -0,0 +1,77 @@
+package fileserver
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+)
+
+// TrailingSlash is the policy for redirecting requests to the canonical URL of dirs and files.
+// See [WithTrailingSlash].
+type TrailingSlash int
+
+const (
+	// Redirects dirs to their URL with a trailing slash, such as '/docs' to '/docs/', and files to
+	// their URL without one, like [http.FileServer]. This is the default, since relative links in
+	// index files only resolve correctly from a URL with a trailing slash.
+	TrailingSlashAdd TrailingSlash = iota
+	// Redirects dirs and files to their URL without a trailing slash, such as '/docs/' to '/docs'.
+	TrailingSlashStrip
+	// Serves dirs and files both with and without a trailing slash.
+	TrailingSlashNone
+)
+
+// Opens the first of the index files of dir that exists, returning its name.
+func (s *Server) openIndex(dir string) (string, fs.File, fs.FileInfo, error) {
+	for _, index := range s.indexFiles {
+		name := path.Join(dir, index)
+		if s.denied(name) {
+			continue
+		}
+		f, err := s.fs.Open(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return "", nil, nil, fsError("open", name, err)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return "", nil, nil, fsError("stat", name, err)
+		}
+		if stat.IsDir() {
+			f.Close()
+			continue
+		}
+		return name, f, stat, nil
+	}
+	return "", nil, nil, &Error{StatusCode: http.StatusNotFound, Op: "index", Path: dir, Err: ErrFileNotFound}
+}
+
+// Returns the relative URL the request for name should be redirected to according to the trailing slash
+// policy, or an empty string if it's already canonical. The root dir is never redirected, since its URL
+// depends on where the server is mounted.
+func (s *Server) canonicalRedirect(name string, dir, trailingSlash bool) string {
+	if name == "." || s.trailingSlash == TrailingSlashNone {
+		return ""
+	}
+	base := path.Base(name)
+	switch {
+	case dir && s.trailingSlash == TrailingSlashAdd && !trailingSlash:
+		return base + "/"
+	case (!dir || s.trailingSlash == TrailingSlashStrip) && trailingSlash:
+		return "../" + base
+	}
+	return ""
+}
+
+// Redirects to the relative URL target, keeping the query string of the request.
+func redirect(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,116 @@
+package fileserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestServerIndexFiles(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []ServerOptFn
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		{
+			name:   "root",
+			path:   "/",
+			status: http.StatusOK,
+			body:   "site/index.html",
+		},
+		{
+			name:     "add trailing slash",
+			opts:     []ServerOptFn{WithIndexFiles("index.html", "index.htm")},
+			path:     "/docs?lang=en",
+			status:   http.StatusMovedPermanently,
+			location: "docs/?lang=en",
+		},
+		{
+			name:   "index fallback",
+			opts:   []ServerOptFn{WithIndexFiles("index.html", "index.htm")},
+			path:   "/docs/",
+			status: http.StatusOK,
+			body:   "site/docs/index.htm",
+		},
+		{
+			name:   "missing index",
+			path:   "/docs/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "missing index is not redirected",
+			path:   "/empty",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "no index files",
+			opts:   []ServerOptFn{WithIndexFiles()},
+			path:   "/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "file with trailing slash",
+			path:     "/index.html/",
+			status:   http.StatusMovedPermanently,
+			location: "../index.html",
+		},
+		{
+			name:     "strip trailing slash",
+			opts:     []ServerOptFn{WithIndexFiles("index.htm"), WithTrailingSlash(TrailingSlashStrip)},
+			path:     "/docs/",
+			status:   http.StatusMovedPermanently,
+			location: "../docs",
+		},
+		{
+			name:   "strip trailing slash (canonical)",
+			opts:   []ServerOptFn{WithIndexFiles("index.htm"), WithTrailingSlash(TrailingSlashStrip)},
+			path:   "/docs",
+			status: http.StatusOK,
+			body:   "site/docs/index.htm",
+		},
+		{
+			name:   "no redirects",
+			opts:   []ServerOptFn{WithIndexFiles("index.htm"), WithTrailingSlash(TrailingSlashNone)},
+			path:   "/docs",
+			status: http.StatusOK,
+			body:   "site/docs/index.htm",
+		},
+		{
+			name:   "no redirects (file)",
+			opts:   []ServerOptFn{WithTrailingSlash(TrailingSlashNone)},
+			path:   "/index.html/",
+			status: http.StatusOK,
+			body:   "site/index.html",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(os.DirFS("testdata/site"), tt.opts...)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			http.StripPrefix("/", h).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("expected Location to be %q but got %q", tt.location, location)
+			}
+			if tt.body != "" {
+				expected, err := os.ReadFile("testdata/" + tt.body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if w.Body.String() != string(expected) {
+					t.Error("mismatched content")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package fileserver
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Listing is the data of a dir listing, rendered by the listing template. See [WithDirListing].
+type Listing struct {
+	// The slash-separated path of the dir, starting with '/'.
+	Path string
+	// The entries of the dir, sorted and filtered according to the query of the request.
+	Entries []ListingEntry
+	// The sort key, one of 'name', 'size', 'modtime' or 'type'.
+	Sort string
+	// Whether entries are sorted in descending order.
+	Desc bool
+	// The filter applied to entry names, if any.
+	Filter string
+}
+
+// Returns the query string that sorts the listing by key. If the listing is already sorted by key,
+// the order is reversed.
+func (l Listing) SortQuery(key string) string {
+	query := url.Values{"sort": {key}}
+	if key == l.Sort && !l.Desc {
+		query.Set("order", "desc")
+	}
+	if l.Filter != "" {
+		query.Set("filter", l.Filter)
+	}
+	return "?" + query.Encode()
+}
+
+// ListingEntry is a file or dir of a [Listing].
+type ListingEntry struct {
+	// The name of the entry.
+	Name string
+	// The relative URL of the entry, which has a trailing slash for dirs.
+	URL string
+	// The size of the entry, in bytes.
+	Size int64
+	// The modification time of the entry.
+	ModTime time.Time
+	// Whether the entry is a dir.
+	IsDir bool
+	// The MIME type of the entry, detected from its extension. It's 'directory' for dirs, and empty
+	// if unknown.
+	Type string
+}
+
+// Returns the size of the entry in a human readable format, such as '1.5 KB'. It's empty for dirs.
+func (e ListingEntry) HumanSize() string {
+	if e.IsDir {
+		return ""
+	}
+	const unit = 1024
+	if e.Size < unit {
+		return strconv.FormatInt(e.Size, 10) + " B"
+	}
+	size, exp := float64(e.Size)/unit, 0
+	for size >= unit && exp < 4 {
+		size /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", size, "KMGTP"[exp])
+}
+
+var defaultListingTemplate = template.Must(template.New("listing").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of {{.Path}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; }
+table { border-collapse: collapse; }
+th, td { padding: 0.25rem 1rem 0.25rem 0; text-align: left; }
+td.size { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<form method="get">
+<input type="hidden" name="sort" value="{{.Sort}}">
+{{if .Desc}}<input type="hidden" name="order" value="desc">{{end}}
+<input type="search" name="filter" value="{{.Filter}}" placeholder="Filter, such as *.js">
+</form>
+<table>
+<thead>
+<tr>
+<th><a href="{{.SortQuery "name"}}">Name</a></th>
+<th><a href="{{.SortQuery "size"}}">Size</a></th>
+<th><a href="{{.SortQuery "modtime"}}">Modified</a></th>
+<th><a href="{{.SortQuery "type"}}">Type</a></th>
+</tr>
+</thead>
+<tbody>
+{{- if ne .Path "/"}}
+<tr><td><a href="../">../</a></td><td></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr>
+<td><a href="{{.URL}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td>
+<td class="size">{{.HumanSize}}</td>
+<td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td>
+<td>{{.Type}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// Serves the listing of the dir name. Entries are sorted with the 'sort' (name, size, modtime or type)
+// and 'order' (asc or desc) query parameters, and filtered with the 'filter' query parameter, which is
+// either a glob such as '*.js' or a case-insensitive substring of the names.
+//
+// Dirs are listed as JSON if the client prefers it, see [JSONListing].
+func (s *Server) serveListing(w http.ResponseWriter, r *http.Request, name string, trailingSlash bool) {
+	addVary(w.Header(), "Accept")
+	if wantsJSONListing(r) {
+		s.serveJSONListing(w, r, name)
+		return
+	}
+
+	dirEntries, err := fs.ReadDir(s.fs, name)
+	if err != nil {
+		s.errHandler(w, r, fsError("list", name, err))
+		return
+	}
+
+	query := r.URL.Query()
+	listing := Listing{
+		Path:   "/",
+		Sort:   query.Get("sort"),
+		Desc:   query.Get("order") == "desc",
+		Filter: query.Get("filter"),
+	}
+	if !slices.Contains([]string{"name", "size", "modtime", "type"}, listing.Sort) {
+		listing.Sort = "name"
+	}
+
+	// Entry URLs are relative to the dir, so they need its name if the URL has no trailing slash.
+	var prefix string
+	if name != "." {
+		listing.Path = "/" + name + "/"
+		if !trailingSlash {
+			prefix = path.Base(name) + "/"
+		}
+	}
+
+	for _, entry := range dirEntries {
+		if s.hidden(path.Join(name, entry.Name())) {
+			continue
+		}
+		if !matchesFilter(entry.Name(), listing.Filter) {
+			continue
+		}
+		// The entry may have been removed since the dir was read.
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		item := ListingEntry{
+			Name:    entry.Name(),
+			URL:     prefix + (&url.URL{Path: entry.Name()}).EscapedPath(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   entry.IsDir(),
+			Type:    mime.TypeByExtension(path.Ext(entry.Name())),
+		}
+		// Names with a colon would be parsed as a URL scheme.
+		if strings.Contains(item.Name, ":") && prefix == "" {
+			item.URL = "./" + item.URL
+		}
+		if item.IsDir {
+			item.URL += "/"
+			item.Size = 0
+			item.Type = "directory"
+		}
+		listing.Entries = append(listing.Entries, item)
+	}
+	sortListing(listing.Entries, listing.Sort, listing.Desc)
+
+	var buf bytes.Buffer
+	if err := s.listingTemplate.Execute(&buf, listing); err != nil {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusInternalServerError, Op: "list", Path: name, Err: err})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// Reports whether name matches filter, which is either a glob or a case-insensitive substring.
+func matchesFilter(name, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	if strings.ContainsAny(filter, "*?[") {
+		ok, _ := path.Match(filter, name)
+		return ok
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(filter))
+}
+
+// Sorts entries by key, always listing dirs first. Ties are broken by name.
+func sortListing(entries []ListingEntry, key string, desc bool) {
+	slices.SortStableFunc(entries, func(a, b ListingEntry) int {
+		if a.IsDir != b.IsDir {
+			if a.IsDir {
+				return -1
+			}
+			return 1
+		}
+
+		var c int
+		switch key {
+		case "size":
+			c = compareInt64(a.Size, b.Size)
+		case "modtime":
+			c = a.ModTime.Compare(b.ModTime)
+		case "type":
+			c = strings.Compare(a.Type, b.Type)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	})
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package fileserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The default number of entries of a JSON listing page.
+	defaultListingLimit = 1000
+	// The maximum number of entries of a JSON listing page.
+	maxListingLimit = 10000
+	// The maximum depth of recursive JSON listings.
+	maxListingDepth = 64
+)
+
+// JSONListing is a page of the JSON listing of a dir, served when the dir is requested with
+// 'Accept: application/json' or '?format=json'. See [WithDirListing].
+//
+// Entries are listed in the order of [fs.WalkDir], which is lexical with dirs followed by their contents.
+// The following query parameters are supported:
+//
+//   - limit: the maximum number of entries of the page, 1000 by default and at most 10000;
+//   - cursor: the next cursor of the previous page;
+//   - depth: how many levels of nested dirs are listed, 1 by default, which only lists the dir itself.
+type JSONListing struct {
+	// The slash-separated path of the dir, starting and ending with '/'.
+	Path string `json:"path"`
+	// The entries of the page.
+	Entries []JSONListingEntry `json:"entries"`
+	// The cursor of the next page, which is empty for the last one.
+	Next string `json:"next,omitempty"`
+}
+
+// JSONListingEntry is a file or dir of a [JSONListing].
+type JSONListingEntry struct {
+	// The name of the entry.
+	Name string `json:"name"`
+	// The slash-separated path of the entry, starting with '/'.
+	Path string `json:"path"`
+	// Either 'file' or 'dir'.
+	Type string `json:"type"`
+	// The size of the entry, in bytes. It's 0 for dirs.
+	Size int64 `json:"size"`
+	// The modification time of the entry.
+	ModTime time.Time `json:"modTime"`
+	// The MIME type of files, detected from their extension, if known.
+	MIMEType string `json:"mimeType,omitempty"`
+	// The ETag of files, if the server calculates ETags and it's known without reading the file: cached
+	// from a previous request, in the [Manifest] or derived from the file metadata, see [WeakETag].
+	ETag string `json:"etag,omitempty"`
+}
+
+// Reports whether the listing of a dir should be served as JSON, either because it's requested with the
+// 'format' query parameter or because the client prefers 'application/json' over 'text/html'.
+func wantsJSONListing(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+
+	var jsonQ, htmlQ float64
+	for _, value := range r.Header.Values("Accept") {
+		for _, member := range strings.Split(value, ",") {
+			mediaType, params, _ := strings.Cut(member, ";")
+			q, ok := parseQuality(params)
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(mediaType)) {
+			case "application/json":
+				jsonQ = max(jsonQ, q)
+			case "text/html":
+				htmlQ = max(htmlQ, q)
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// Serves a page of the JSON listing of the dir name, see [JSONListing].
+func (s *Server) serveJSONListing(w http.ResponseWriter, r *http.Request, name string) {
+	query := r.URL.Query()
+	limit, err := intQuery(query.Get("limit"), defaultListingLimit, 1, maxListingLimit)
+	if err != nil {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusBadRequest, Op: "list", Path: name, Err: fmt.Errorf("%w: limit: %w", ErrInvalidQuery, err)})
+		return
+	}
+	depth, err := intQuery(query.Get("depth"), 1, 1, maxListingDepth)
+	if err != nil {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusBadRequest, Op: "list", Path: name, Err: fmt.Errorf("%w: depth: %w", ErrInvalidQuery, err)})
+		return
+	}
+	var cursor string
+	if value := query.Get("cursor"); value != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			s.errHandler(w, r, &Error{StatusCode: http.StatusBadRequest, Op: "list", Path: name, Err: fmt.Errorf("%w: cursor: %w", ErrInvalidQuery, err)})
+			return
+		}
+		cursor = string(decoded)
+	}
+
+	listing := JSONListing{Path: "/", Entries: []JSONListingEntry{}}
+	if name != "." {
+		listing.Path = "/" + name + "/"
+	}
+
+	err = fs.WalkDir(s.fs, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == name {
+			return nil
+		}
+		rel := strings.TrimPrefix(p, listing.Path[1:])
+		if s.hidden(p) {
+			return skipEntry(d)
+		}
+		// Dirs at the depth limit are listed, but not their contents.
+		lastLevel := d.IsDir() && strings.Count(rel, "/")+1 >= depth
+
+		// Entries up to the cursor were listed in previous pages, and so were the contents of dirs that
+		// precede it, unless the cursor is the dir itself or inside it.
+		if cursor != "" && !walkOrderLess(cursor, rel) {
+			if d.IsDir() && (lastLevel || (rel != cursor && !strings.HasPrefix(cursor, rel+"/"))) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if len(listing.Entries) == limit {
+			listing.Next = base64.RawURLEncoding.EncodeToString([]byte(listing.Entries[limit-1].Path[len(listing.Path):]))
+			return fs.SkipAll
+		}
+
+		entry, err := s.jsonListingEntry(p, d)
+		if err != nil {
+			return err
+		}
+		listing.Entries = append(listing.Entries, entry)
+
+		if lastLevel {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		s.errHandler(w, r, fsError("list", name, err))
+		return
+	}
+
+	body, err := json.Marshal(listing)
+	if err != nil {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusInternalServerError, Op: "list", Path: name, Err: err})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// Creates the listing entry of the file or dir p.
+func (s *Server) jsonListingEntry(p string, d fs.DirEntry) (JSONListingEntry, error) {
+	info, err := d.Info()
+	if err != nil {
+		return JSONListingEntry{}, err
+	}
+
+	entry := JSONListingEntry{
+		Name:    d.Name(),
+		Path:    "/" + p,
+		Type:    "file",
+		ModTime: info.ModTime(),
+	}
+	if d.IsDir() {
+		entry.Type = "dir"
+		return entry, nil
+	}
+	entry.Size = info.Size()
+	entry.MIMEType = mime.TypeByExtension(path.Ext(p))
+
+	// Hashing every file of a page would read up to the whole dir on a single request, so only ETags
+	// known without reading the file are listed.
+	if s.etagFn != nil || s.fileETagFn != nil || s.manifest != nil {
+		entry.ETag = s.knownETag(p, info)
+	}
+	return entry, nil
+}
+
+// Skips the entry d while walking a dir, along with its contents if it's a dir.
+func skipEntry(d fs.DirEntry) error {
+	if d.IsDir() {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// Reports whether the slash-separated path a comes before b in the order of [fs.WalkDir], which
+// compares paths element by element, so dirs are followed by their contents.
+func walkOrderLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// Parses the integer query parameter value, which must be within min and max. Returns fallback
+// if value is empty.
+func intQuery(value string, fallback, min, max int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("must be between %d and %d", min, max)
+	}
+	return n, nil
+}
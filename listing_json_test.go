@@ -0,0 +1,217 @@
+package fileserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWantsJSONListing(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		accept   string
+		expected bool
+	}{
+		{name: "no accept", target: "/", expected: false},
+		{name: "json", target: "/", accept: "application/json", expected: true},
+		{name: "browser", target: "/", accept: "text/html,application/xhtml+xml,*/*;q=0.8", expected: false},
+		{name: "json preferred", target: "/", accept: "text/html;q=0.5, application/json", expected: true},
+		{name: "html preferred", target: "/", accept: "text/html, application/json;q=0.9", expected: false},
+		{name: "format json", target: "/?format=json", accept: "text/html", expected: true},
+		{name: "format html", target: "/?format=html", accept: "application/json", expected: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if result := wantsJSONListing(r); result != tt.expected {
+				t.Errorf("expected result to be %t but got %t", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestWalkOrderLess(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "a", b: "b", expected: true},
+		{a: "a", b: "a/b", expected: true},
+		{a: "a/b", b: "a.txt", expected: true},
+		{a: "a.txt", b: "a/b", expected: false},
+		{a: "a/b", b: "a/b", expected: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if result := walkOrderLess(tt.a, tt.b); result != tt.expected {
+				t.Errorf("expected result to be %t but got %t", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestServerJSONListing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":         {Data: []byte("a")},
+		"a/b.txt":       {Data: []byte("ab")},
+		"a/c/d.txt":     {Data: []byte("acd")},
+		"a.b/e.js":      {Data: []byte("e")},
+		"f.css":         {Data: []byte("f")},
+		".hidden/g.txt": {Data: []byte("g")},
+	}
+	h := http.StripPrefix("/", New(fsys, WithDirListing(true)))
+
+	list := func(t *testing.T, target string) (JSONListing, int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept", "application/json")
+		h.ServeHTTP(w, r)
+
+		var listing JSONListing
+		if w.Code == http.StatusOK {
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("expected Content-Type to be %q but got %q", "application/json", contentType)
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+				t.Fatalf("unexpected error decoding listing: %s", err)
+			}
+		}
+		return listing, w.Code
+	}
+	paths := func(listing JSONListing) []string {
+		var paths []string
+		for _, entry := range listing.Entries {
+			paths = append(paths, entry.Path)
+		}
+		return paths
+	}
+
+	t.Run("entries", func(t *testing.T) {
+		listing, status := list(t, "/")
+		if status != http.StatusOK {
+			t.Fatalf("expected status to be %d but got %d", http.StatusOK, status)
+		}
+
+		expected := []string{"/a", "/a.b", "/a.txt", "/f.css"}
+		if !slices.Equal(paths(listing), expected) {
+			t.Errorf("expected paths to be %v but got %v", expected, paths(listing))
+		}
+		if listing.Next != "" {
+			t.Errorf("expected no next cursor but got %q", listing.Next)
+		}
+
+		// Files aren't hashed to list them.
+		entry := listing.Entries[2]
+		if entry.Type != "file" || entry.Size != 1 || entry.MIMEType != "text/plain; charset=utf-8" || entry.ETag != "" {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+		if dir := listing.Entries[0]; dir.Type != "dir" || dir.ETag != "" {
+			t.Errorf("unexpected dir entry %+v", dir)
+		}
+	})
+
+	t.Run("cached etags", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/f.css", nil))
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("expected an ETag")
+		}
+
+		listing, _ := list(t, "/")
+		for _, entry := range listing.Entries {
+			switch entry.Path {
+			case "/f.css":
+				if entry.ETag != etag {
+					t.Errorf("expected cached ETag to be %s but got %s", etag, entry.ETag)
+				}
+			case "/a.txt":
+				if entry.ETag != "" {
+					t.Errorf("expected no ETag for a file that wasn't served but got %s", entry.ETag)
+				}
+			}
+		}
+	})
+
+	t.Run("paginated", func(t *testing.T) {
+		expected := []string{"/a", "/a/b.txt", "/a/c", "/a/c/d.txt", "/a.b", "/a.b/e.js", "/a.txt", "/f.css"}
+
+		var (
+			result []string
+			cursor string
+		)
+		for i := 0; i < len(expected); i++ {
+			listing, status := list(t, "/?depth=3&limit=3&cursor="+cursor)
+			if status != http.StatusOK {
+				t.Fatalf("expected status to be %d but got %d", http.StatusOK, status)
+			}
+			result = append(result, paths(listing)...)
+			if listing.Next == "" {
+				break
+			}
+			cursor = listing.Next
+		}
+		if !slices.Equal(result, expected) {
+			t.Errorf("expected paths to be %v but got %v", expected, result)
+		}
+	})
+
+	t.Run("subdir depth", func(t *testing.T) {
+		listing, _ := list(t, "/a/?depth=1")
+		expected := []string{"/a/b.txt", "/a/c"}
+		if !slices.Equal(paths(listing), expected) {
+			t.Errorf("expected paths to be %v but got %v", expected, paths(listing))
+		}
+		if listing.Path != "/a/" {
+			t.Errorf("expected path to be %q but got %q", "/a/", listing.Path)
+		}
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		for _, query := range []string{"?cursor=!", "?limit=0", "?depth=abc"} {
+			if _, status := list(t, "/"+query); status != http.StatusBadRequest {
+				t.Errorf("expected status of %q to be %d but got %d", query, http.StatusBadRequest, status)
+			}
+		}
+	})
+}
+
+func TestServerJSONListingWeakETags(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("a"), ModTime: time.Unix(1700000000, 0)},
+		"b.txt": {Data: []byte("b")},
+	}
+	h := http.StripPrefix("/", New(fsys, WithDirListing(true), WithFileETagFunc(WeakETag)))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json")
+	h.ServeHTTP(w, r)
+
+	var listing JSONListing
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("unexpected error decoding listing: %s", err)
+	}
+	if len(listing.Entries) != 2 {
+		t.Fatalf("expected 2 entries but got %d", len(listing.Entries))
+	}
+	if etag := listing.Entries[0].ETag; !strings.HasPrefix(etag, "W/") {
+		t.Errorf("expected a weak ETag derived from the metadata but got %q", etag)
+	}
+	// Without a modification time, WeakETag needs to read the file.
+	if etag := listing.Entries[1].ETag; etag != "" {
+		t.Errorf("expected no ETag but got %q", etag)
+	}
+}
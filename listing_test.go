@@ -0,0 +1,183 @@
+package fileserver
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestServerDirListing(t *testing.T) {
+	modTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"b.js":                     {Data: []byte("console.log(1)"), ModTime: modTime},
+		"a.css":                    {Data: []byte("body { color: red; }"), ModTime: modTime.Add(time.Hour)},
+		"c.txt":                    {Data: []byte("c"), ModTime: modTime.Add(-time.Hour)},
+		".env":                     {Data: []byte("SECRET=1")},
+		"<b onclick=alert(1)>.txt": {Data: []byte("xss")},
+		"sub/file.txt":             {Data: []byte("file")},
+	}
+
+	testCases := []struct {
+		name     string
+		opts     []ServerOptFn
+		path     string
+		status   int
+		location string
+		contains []string
+		excludes []string
+		order    []string
+	}{
+		{
+			name:   "disabled",
+			path:   "/",
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "root",
+			opts:     []ServerOptFn{WithDirListing(true)},
+			path:     "/",
+			status:   http.StatusOK,
+			contains: []string{"Index of /", `href="sub/"`, `href="b.js"`},
+			excludes: []string{".env", `href="../"`},
+			order:    []string{"sub/", "a.css", "b.js", "c.txt"},
+		},
+		{
+			name:     "escaped names",
+			opts:     []ServerOptFn{WithDirListing(true)},
+			path:     "/",
+			status:   http.StatusOK,
+			contains: []string{"&lt;b onclick=alert(1)&gt;.txt", `href="%3Cb%20onclick=alert%281%29%3E.txt"`},
+			excludes: []string{"<b onclick"},
+		},
+		{
+			name:     "dotfiles",
+			opts:     []ServerOptFn{WithDirListing(true), WithListingDotfiles(true)},
+			path:     "/",
+			status:   http.StatusOK,
+			contains: []string{`href=".env"`},
+		},
+		{
+			name:   "sort by modtime desc",
+			opts:   []ServerOptFn{WithDirListing(true)},
+			path:   "/?sort=modtime&order=desc",
+			status: http.StatusOK,
+			order:  []string{"sub/", "a.css", "b.js", "c.txt"},
+		},
+		{
+			name:   "sort by size",
+			opts:   []ServerOptFn{WithDirListing(true)},
+			path:   "/?sort=size",
+			status: http.StatusOK,
+			order:  []string{"sub/", "c.txt", "b.js", "a.css"},
+		},
+		{
+			name:     "filter glob",
+			opts:     []ServerOptFn{WithDirListing(true)},
+			path:     "/?filter=*.js",
+			status:   http.StatusOK,
+			contains: []string{`href="b.js"`},
+			excludes: []string{`href="a.css"`, `href="sub/"`},
+		},
+		{
+			name:     "filter substring",
+			opts:     []ServerOptFn{WithDirListing(true)},
+			path:     "/?filter=CS",
+			status:   http.StatusOK,
+			contains: []string{`href="a.css"`},
+			excludes: []string{`href="b.js"`},
+		},
+		{
+			name:     "subdir",
+			opts:     []ServerOptFn{WithDirListing(true)},
+			path:     "/sub/",
+			status:   http.StatusOK,
+			contains: []string{"Index of /sub/", `href="../"`, `href="file.txt"`},
+		},
+		{
+			name:     "subdir redirect",
+			opts:     []ServerOptFn{WithDirListing(true)},
+			path:     "/sub",
+			status:   http.StatusMovedPermanently,
+			location: "sub/",
+		},
+		{
+			name:     "subdir without trailing slash",
+			opts:     []ServerOptFn{WithDirListing(true), WithTrailingSlash(TrailingSlashNone)},
+			path:     "/sub",
+			status:   http.StatusOK,
+			contains: []string{`href="sub/file.txt"`},
+		},
+		{
+			name: "custom template",
+			opts: []ServerOptFn{
+				WithDirListing(true),
+				WithListingTemplate(template.Must(template.New("").Parse(`{{range .Entries}}[{{.Name}}]{{end}}`))),
+			},
+			path:     "/sub/",
+			status:   http.StatusOK,
+			contains: []string{"[file.txt]"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(fsys, tt.opts...)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			http.StripPrefix("/", h).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("expected Location to be %q but got %q", tt.location, location)
+			}
+
+			body := w.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected body to contain %q", s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("expected body not to contain %q", s)
+				}
+			}
+
+			last := -1
+			for _, name := range tt.order {
+				i := strings.Index(body, `href="`+name+`"`)
+				if i < last {
+					t.Errorf("expected %q to be listed after the previous entries", name)
+				}
+				last = i
+			}
+		})
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	testCases := []struct {
+		entry    ListingEntry
+		expected string
+	}{
+		{entry: ListingEntry{Size: 512}, expected: "512 B"},
+		{entry: ListingEntry{Size: 1536}, expected: "1.5 KB"},
+		{entry: ListingEntry{Size: 5 << 20}, expected: "5.0 MB"},
+		{entry: ListingEntry{IsDir: true}, expected: ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.expected, func(t *testing.T) {
+			if size := tt.entry.HumanSize(); size != tt.expected {
+				t.Errorf("expected size to be %q but got %q", tt.expected, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package fileserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// Manifest holds file metadata computed at build time, such as the ETags of the files of an immutable
+// deploy artifact, so they don't need to be calculated at runtime. See [WithManifest].
+//
+// It's usually generated with the 'fileserver manifest' command and stored as JSON:
+//
+//	{
+//	  "files": {
+//	    "assets/app.js": {
+//	      "etag": "\"6cd3556deb0da54bca060b4c39479839\"",
+//	      "size": 4000,
+//	      "sha256": "315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3",
+//	      "encodings": {"br": 221, "gzip": 383}
+//	    }
+//	  }
+//	}
+type Manifest struct {
+	// Files by their slash-separated path, relative to the root of the [fs.FS].
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+// ManifestEntry holds the metadata of a single file.
+type ManifestEntry struct {
+	// The ETag of the file contents, including quotes.
+	ETag string `json:"etag"`
+	// The file size, in bytes. Entries are ignored if the size of the served file differs.
+	Size int64 `json:"size"`
+	// The hex encoded SHA-256 hash of the file contents.
+	SHA256 string `json:"sha256,omitempty"`
+	// The sizes, in bytes, of the precompressed sidecar files by encoding.
+	Encodings map[string]int64 `json:"encodings,omitempty"`
+}
+
+// Reports whether e and other describe the same file contents.
+func (e ManifestEntry) sameContent(other ManifestEntry) bool {
+	return e.Size == other.Size && e.ETag == other.ETag && e.SHA256 == other.SHA256
+}
+
+// Reads a JSON encoded [Manifest] from r.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("fileserver: failed to decode manifest: %w", err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]ManifestEntry)
+	}
+	return &m, nil
+}
+
+// Reads a JSON encoded [Manifest] from the file name in fsys.
+func LoadManifest(fsys fs.FS, name string) (*Manifest, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("fileserver: failed to open manifest: %w", err)
+	}
+	defer f.Close()
+	return ReadManifest(f)
+}
+
+// Builds the [Manifest] of all files in fsys, calculating ETags with etagFn. If etagFn is nil,
+// [MD5ETag] is used. Precompressed sidecar files are recorded in the encodings of the file they
+// were compressed from, besides having entries of their own.
+func BuildManifest(fsys fs.FS, etagFn ETagFunc) (*Manifest, error) {
+	if etagFn == nil {
+		etagFn = MD5ETag
+	}
+
+	m := &Manifest{Files: make(map[string]ManifestEntry)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		entry, err := buildManifestEntry(fsys, name, etagFn)
+		if err != nil {
+			return fmt.Errorf("fileserver: failed to build manifest entry for %s: %w", name, err)
+		}
+		m.Files[name] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, entry := range m.Files {
+		for encoding, ext := range sidecarExts {
+			if !strings.HasSuffix(name, ext) {
+				continue
+			}
+			originalName := strings.TrimSuffix(name, ext)
+			original, ok := m.Files[originalName]
+			if !ok {
+				continue
+			}
+			if original.Encodings == nil {
+				original.Encodings = make(map[string]int64)
+			}
+			original.Encodings[encoding] = entry.Size
+			m.Files[originalName] = original
+		}
+	}
+	return m, nil
+}
+
+// Hashes the file name with both etagFn and SHA-256, reading it only once.
+func buildManifestEntry(fsys fs.FS, name string, etagFn ETagFunc) (ManifestEntry, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	counter := &countingWriter{}
+	etag, err := etagFn(io.TeeReader(f, io.MultiWriter(hasher, counter)))
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	// The ETag function may not read the whole file.
+	if _, err := io.Copy(io.MultiWriter(hasher, counter), f); err != nil {
+		return ManifestEntry{}, err
+	}
+
+	return ManifestEntry{
+		ETag:   etag,
+		Size:   counter.n,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// Returns the entry of the file, if present in the manifest and matching its size.
+func (m *Manifest) lookup(name string, stat fs.FileInfo) (ManifestEntry, bool) {
+	entry, ok := m.Files[name]
+	if !ok || entry.Size != stat.Size() {
+		return ManifestEntry{}, false
+	}
+	return entry, true
+}
+
+// Returns h with the hashes of the manifest entry added, without overriding existing ones.
+func (h fileHashes) withManifest(entry ManifestEntry) fileHashes {
+	if h.etag == "" {
+		h.etag = entry.ETag
+	}
+	if _, ok := h.digests[DigestSHA256]; !ok && entry.SHA256 != "" {
+		if digest, err := hex.DecodeString(entry.SHA256); err == nil {
+			digests := map[string][]byte{DigestSHA256: digest}
+			for algorithm, d := range h.digests {
+				digests[algorithm] = d
+			}
+			h.digests = digests
+		}
+	}
+	return h
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	c.n += int64(len(b))
+	return len(b), nil
+}
@@ -0,0 +1,137 @@
+package fileserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":            {Data: []byte("Hello, world!")},
+		"assets/app.js":         {Data: []byte("console.log('app')")},
+		"assets/app.js.gz":      {Data: []byte("gzipped")},
+		"assets/app.js.br":      {Data: []byte("br")},
+		"assets/orphan.css.zst": {Data: []byte("zstd")},
+	}
+
+	m, err := BuildManifest(fsys, nil)
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %s", err)
+	}
+
+	if len(m.Files) != len(fsys) {
+		t.Fatalf("expected %d entries but got %d", len(fsys), len(m.Files))
+	}
+
+	index := m.Files["index.html"]
+	if index.ETag != `"6cd3556deb0da54bca060b4c39479839"` {
+		t.Errorf("unexpected etag %s", index.ETag)
+	}
+	if index.SHA256 != "315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3" {
+		t.Errorf("unexpected sha256 %s", index.SHA256)
+	}
+	if index.Size != 13 {
+		t.Errorf("expected size to be 13 but got %d", index.Size)
+	}
+
+	app := m.Files["assets/app.js"]
+	if app.Encodings["gzip"] != 7 || app.Encodings["br"] != 2 || len(app.Encodings) != 2 {
+		t.Errorf("unexpected encodings %v", app.Encodings)
+	}
+
+	// Round trip through JSON.
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadManifest(buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading manifest: %s", err)
+	}
+	if decoded.Files["assets/app.js"].Encodings["gzip"] != 7 {
+		t.Error("expected manifest to survive a round trip")
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	if _, err := ReadManifest(strings.NewReader("{")); err == nil {
+		t.Error("expected error decoding invalid manifest")
+	}
+
+	m, err := ReadManifest(strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if m.Files == nil {
+		t.Error("expected files to be initialized")
+	}
+
+	if _, err := LoadManifest(fstest.MapFS{}, "manifest.json"); err == nil {
+		t.Error("expected error loading missing manifest")
+	}
+}
+
+func TestServerManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manifest.json": {Data: []byte(`{"files": {
+			"listed.txt": {"etag": "\"from-manifest\"", "size": 5},
+			"resized.txt": {"etag": "\"stale\"", "size": 1}
+		}}`)},
+		"listed.txt":   {Data: []byte("hello")},
+		"resized.txt":  {Data: []byte("hello")},
+		"unlisted.txt": {Data: []byte("hello")},
+	}
+	m, err := LoadManifest(fsys, "manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		opts     []ServerOptFn
+		expected string
+	}{
+		{
+			name:     "listed",
+			path:     "listed.txt",
+			expected: `"from-manifest"`,
+		},
+		{
+			name:     "listed (etag func disabled)",
+			path:     "listed.txt",
+			opts:     []ServerOptFn{WithETagFunc(nil)},
+			expected: `"from-manifest"`,
+		},
+		{
+			name:     "size mismatch",
+			path:     "resized.txt",
+			expected: `"5d41402abc4b2a76b9719d911017c592"`,
+		},
+		{
+			name:     "unlisted",
+			path:     "unlisted.txt",
+			expected: `"5d41402abc4b2a76b9719d911017c592"`,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(fsys, append(tt.opts, WithManifest(m))...)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = tt.path
+			h.ServeHTTP(w, r)
+
+			if etag := w.Header().Get("ETag"); etag != tt.expected {
+				t.Errorf("expected etag to be %s but got %s", tt.expected, etag)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package fileserver
+
+import (
+	"mime"
+	"strings"
+)
+
+// Default minimum compression gain. Compressed contents must be at least 10% smaller than the
+// original file to be served.
+const DefaultMinCompressionGain = 0.1
+
+// Function used to decide whether a file should be compressed on-the-fly, given its name,
+// detected MIME type (the value of the Content-Type header) and size in bytes.
+type CompressionPolicy func(name, contentType string, size int64) bool
+
+// Content types of formats that are already compressed, for which compressing again wastes CPU
+// and often makes the response larger.
+var incompressibleTypes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/vnd.rar",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/zstd",
+	"application/x-brotli",
+	"application/pdf",
+	"application/octet-stream",
+	"application/vnd.ms-fontobject",
+	"font/woff",
+	"font/woff2",
+}
+
+// Compresses all files, except for the ones with known incompressible content types: images (other
+// than SVG and BMP), audio, video, fonts in the WOFF formats and archives.
+func DefaultCompressionPolicy(_, contentType string, _ int64) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case mediaType == "image/svg+xml", mediaType == "image/bmp", mediaType == "image/x-icon", mediaType == "image/vnd.microsoft.icon":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return false
+	}
+	for _, t := range incompressibleTypes {
+		if mediaType == t {
+			return false
+		}
+	}
+	return true
+}
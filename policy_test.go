@@ -0,0 +1,33 @@
+package fileserver
+
+import "testing"
+
+func TestDefaultCompressionPolicy(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		expected    bool
+	}{
+		{contentType: "text/javascript; charset=utf-8", expected: true},
+		{contentType: "text/html; charset=utf-8", expected: true},
+		{contentType: "application/json", expected: true},
+		{contentType: "application/wasm", expected: true},
+		{contentType: "image/svg+xml", expected: true},
+		{contentType: "image/png", expected: false},
+		{contentType: "image/jpeg", expected: false},
+		{contentType: "video/mp4", expected: false},
+		{contentType: "audio/mpeg", expected: false},
+		{contentType: "font/woff2", expected: false},
+		{contentType: "application/zip", expected: false},
+		{contentType: "application/gzip", expected: false},
+		{contentType: "", expected: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.contentType, func(t *testing.T) {
+			result := DefaultCompressionPolicy("file", tt.contentType, 4096)
+			if result != tt.expected {
+				t.Errorf("expected result to be %t but got %t", tt.expected, result)
+			}
+		})
+	}
+}
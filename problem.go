@@ -0,0 +1,145 @@
+package fileserver
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The base of the problem type URIs of the errors of this package, which link to their documentation.
+const problemTypeBase = "https://pkg.go.dev/github.com/ffss92/fileserver#"
+
+// Problem holds the problem details (RFC 9457) of an error response, as sent by [NegotiatingErrorHandler].
+type Problem struct {
+	// A URI identifying the problem type. Errors of this package link to their documentation, such as
+	// 'https://pkg.go.dev/github.com/ffss92/fileserver#ErrFileNotFound', while unknown errors are
+	// 'about:blank'.
+	Type string `json:"type"`
+	// A short summary of the problem type.
+	Title string `json:"title"`
+	// The status code of the response.
+	Status int `json:"status"`
+	// The path of the request.
+	Instance string `json:"instance"`
+}
+
+// Media types of error responses, in order of preference when the client accepts more than one equally.
+var errorMediaTypes = []string{"text/plain", "text/html", "application/problem+json", "application/json"}
+
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.Title}}</title>
+</head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+</body>
+</html>
+`))
+
+// An [ErrorHandlerFunc] that responds in the format the client prefers according to the Accept header,
+// either plain text, HTML, or problem details (RFC 9457) as 'application/problem+json'. Plain text is sent
+// if the client doesn't accept any of them.
+//
+// Errors of this package have stable problem types, see [Problem].
+func NegotiatingErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status, title := errorStatus(err)
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    title,
+		Status:   status,
+		Instance: requestPath(r),
+	}
+	if known, ok := lookupError(err); ok && known.status == status {
+		problem.Type = problemTypeBase + known.name
+	}
+
+	for _, header := range representationHeaders {
+		w.Header().Del(header)
+	}
+	addVary(w.Header(), "Accept")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	switch negotiateMediaType(r, errorMediaTypes) {
+	case "text/html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(problem.Status)
+		_ = errorPageTemplate.Execute(w, problem)
+	case "application/problem+json", "application/json":
+		body, _ := json.Marshal(problem)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(problem.Status)
+		_, _ = w.Write(body)
+	default:
+		http.Error(w, problem.Title, problem.Status)
+	}
+}
+
+// Returns the path of the request as sent by the client, before prefixes are stripped by
+// [http.StripPrefix].
+func requestPath(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+// Picks the media type of the response from offered, which must be ordered by server preference,
+// according to the Accept header of r. Without the header, the first offered type is picked. If
+// none is acceptable, an empty string is returned.
+func negotiateMediaType(r *http.Request, offered []string) string {
+	values := r.Header.Values("Accept")
+	if len(values) == 0 {
+		return offered[0]
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, offer := range offered {
+		if q := mediaTypeQuality(values, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// Returns the quality value of mediaType in the Accept header values. The most specific matching
+// media range is used, so 'text/html' takes precedence over 'text/*', which takes precedence over '*/*'.
+func mediaTypeQuality(values []string, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	var (
+		q           float64
+		specificity = -1
+	)
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			mediaRange, params, _ := strings.Cut(member, ";")
+			mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+			var s int
+			switch mediaRange {
+			case mediaType:
+				s = 2
+			case typ + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			default:
+				continue
+			}
+			rangeQ, ok := parseQuality(params)
+			if ok && s > specificity {
+				q, specificity = rangeQ, s
+			}
+		}
+	}
+	return q
+}
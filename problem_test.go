@@ -0,0 +1,126 @@
+package fileserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	testCases := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "", expected: "text/plain"},
+		{accept: "*/*", expected: "text/plain"},
+		{accept: "text/html,application/xhtml+xml,*/*;q=0.8", expected: "text/html"},
+		{accept: "application/problem+json", expected: "application/problem+json"},
+		{accept: "application/json, */*;q=0.1", expected: "application/json"},
+		{accept: "text/*;q=0.5, application/*", expected: "application/problem+json"},
+		{accept: "text/*, text/plain;q=0", expected: "text/html"},
+		{accept: "image/png", expected: ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if result := negotiateMediaType(r, errorMediaTypes); result != tt.expected {
+				t.Errorf("expected media type to be %q but got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNegotiatingErrorHandler(t *testing.T) {
+	h := http.StripPrefix("/static/", New(os.DirFS("testdata"), WithErrorHandler(NegotiatingErrorHandler)))
+
+	testCases := []struct {
+		name        string
+		accept      string
+		contentType string
+		contains    string
+	}{
+		{
+			name:        "plain text",
+			accept:      "",
+			contentType: "text/plain; charset=utf-8",
+			contains:    "file not found",
+		},
+		{
+			name:        "html",
+			accept:      "text/html",
+			contentType: "text/html; charset=utf-8",
+			contains:    "<h1>404 file not found</h1>",
+		},
+		{
+			name:        "problem",
+			accept:      "application/problem+json",
+			contentType: "application/problem+json",
+		},
+		{
+			name:        "unacceptable",
+			accept:      "image/png",
+			contentType: "text/plain; charset=utf-8",
+			contains:    "file not found",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/static/missing.txt", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("expected status to be %d but got %d", http.StatusNotFound, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("expected Content-Type to be %q but got %q", tt.contentType, contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("expected body to contain %q but got %q", tt.contains, w.Body.String())
+			}
+
+			if tt.contentType == "application/problem+json" {
+				var problem Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+					t.Fatalf("unexpected error decoding problem: %s", err)
+				}
+				expected := Problem{
+					Type:     "https://pkg.go.dev/github.com/ffss92/fileserver#ErrFileNotFound",
+					Title:    "file not found",
+					Status:   http.StatusNotFound,
+					Instance: "/static/missing.txt",
+				}
+				if problem != expected {
+					t.Errorf("expected problem to be %+v but got %+v", expected, problem)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		r.Header.Set("Accept", "application/problem+json")
+		NegotiatingErrorHandler(w, r, errors.New("boom"))
+
+		var problem Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("unexpected error decoding problem: %s", err)
+		}
+		if problem.Type != "about:blank" || problem.Status != http.StatusInternalServerError {
+			t.Errorf("unexpected problem %+v", problem)
+		}
+	})
+}
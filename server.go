@@ -2,19 +2,25 @@ package fileserver
 
 import (
 	"bytes"
-	"compress/gzip"
 	"errors"
-	"fmt"
+	"hash"
+	"html/template"
 	"io"
 	"io/fs"
+	"mime"
 	"net/http"
 	"os"
+	"path"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 const (
-	// TODO: Make this a configuration, 15mb for now.
-	maxCompressSize = 15 << 20
+	// Files smaller than or equal to this size, in bytes, aren't compressed by default.
+	defaultMinCompressSize = 1024
+	// Files larger than or equal to this size, in bytes, aren't compressed in memory by default, 15mb.
+	defaultMaxCompressSize = 15 << 20
 )
 
 var _ http.Handler = (*Server)(nil)
@@ -25,15 +31,46 @@ var _ http.Handler = (*Server)(nil)
 // Range, If-Range, If-Match, If-None-Match, If-Modified-Since
 // and If-Unmodified-Since through the use of [http.ServeContent].
 //
+// Responses are compressed with brotli, zstd or gzip, according to the client's
+// preferences. Precompressed sidecar files, such as 'app.js.br', 'app.js.zst' and
+// 'app.js.gz', are served in place of the requested file when accepted by the client.
+//
 // By default, ETag generation is done by md5 hashing the file contents
-// and Cache-Control is set to 'no-cache'. This behavior is configurable
+// and Cache-Control is set to 'no-cache'. Compressed responses get their own
+// ETag, suffixed with the encoding, such as '"<hash>-gzip"'. This behavior is configurable
 // by creating a new File Server using [fileserver.New] and providing the
 // desired [fileserver.ServerOptFn] functional options.
 type Server struct {
-	fs             fs.FS
-	etagFn         ETagFunc
-	errHandler     ErrorHandlerFunc
-	cacheControlFn CacheControlFunc
+	fs                fs.FS
+	etagFn            ETagFunc
+	fileETagFn        FileETagFunc
+	errHandler        ErrorHandlerFunc
+	cacheControlFn    CacheControlFunc
+	cacheHeadersFn    CacheHeadersFunc
+	surrogateKeysFn   SurrogateKeysFunc
+	precompressed     bool
+	encodings         []string
+	encoders          map[string]EncoderFunc
+	cache             *compressionCache
+	minCompress       int64
+	maxCompress       int64
+	streaming         bool
+	compressionPolicy CompressionPolicy
+	minGain           float64
+	dictionaries      *dictionaries
+	etags             *etagCache
+	manifest          *Manifest
+	digests           []string
+	indexFiles        []string
+	trailingSlash     TrailingSlash
+	listing           bool
+	listingTemplate   *template.Template
+	listDotfiles      bool
+	deny              []string
+	archives          bool
+	maxArchiveEntries int
+	maxArchiveSize    int64
+	spaFallbackFn     FallbackFunc
 }
 
 // Creates a new [Server]. It can be configured using functional options.
@@ -41,11 +78,27 @@ type Server struct {
 //	fileServer := New(myFS, WithErrorHandler(myErrorHandlerFunc))
 //	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
 func New(fs fs.FS, opts ...ServerOptFn) *Server {
+	encodings, encoders := defaultEncoders()
 	server := &Server{
-		fs:             fs,
-		etagFn:         calculateETag,
-		errHandler:     defaultErrorHandler,
-		cacheControlFn: NoCache,
+		fs:                fs,
+		etagFn:            calculateETag,
+		errHandler:        defaultErrorHandler,
+		cacheControlFn:    NoCache,
+		precompressed:     true,
+		encodings:         encodings,
+		encoders:          encoders,
+		cache:             newCompressionCache(defaultCompressionCacheSize),
+		minCompress:       defaultMinCompressSize,
+		maxCompress:       defaultMaxCompressSize,
+		compressionPolicy: DefaultCompressionPolicy,
+		minGain:           DefaultMinCompressionGain,
+		etags:             newETagCache(defaultETagCacheSize),
+		indexFiles:        []string{"index.html"},
+		trailingSlash:     TrailingSlashAdd,
+		listingTemplate:   defaultListingTemplate,
+		maxArchiveEntries: defaultMaxArchiveEntries,
+		maxArchiveSize:    defaultMaxArchiveSize,
+		spaFallbackFn:     NavigationFallback(),
 	}
 	for _, opt := range opts {
 		opt(server)
@@ -64,68 +117,175 @@ func Serve(dir string, opts ...ServerOptFn) http.Handler {
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet && r.Method != http.MethodHead {
-		s.errHandler(w, r, ErrInvalidMethod)
+	if !s.checkMethod(w, r) {
 		return
 	}
 
-	fileName := r.URL.Path
+	// The root dir of the [fs.FS] is requested with an empty path, or with '/' if the server isn't mounted
+	// with [http.StripPrefix].
+	trailingSlash := strings.HasSuffix(r.URL.Path, "/")
+	fileName := strings.TrimSuffix(r.URL.Path, "/")
 	if fileName == "" {
-		s.errHandler(w, r, ErrFileNotFound)
+		fileName = "."
+	}
+	if s.denied(fileName) {
+		s.errHandler(w, r, fsError("open", fileName, fs.ErrNotExist))
 		return
 	}
 
 	file, err := s.fs.Open(fileName)
 	if err != nil {
-		switch {
-		case errors.Is(err, fs.ErrInvalid):
-			s.errHandler(w, r, ErrInvalidPath)
-		case errors.Is(err, fs.ErrNotExist):
-			s.errHandler(w, r, ErrFileNotFound)
-		default:
-			s.errHandler(w, r, fmt.Errorf("failed to open file: %w", err))
-		}
+		s.errHandler(w, r, fsError("open", fileName, err))
 		return
 	}
 	defer file.Close()
 
 	stat, err := file.Stat()
 	if err != nil {
-		s.errHandler(w, r, fmt.Errorf("failed to stat file: %w", err))
+		s.errHandler(w, r, fsError("stat", fileName, err))
 		return
 	}
-	if stat.IsDir() {
-		s.errHandler(w, r, ErrFileNotFound)
+
+	// Dirs are served from their index file, if any, or listed if enabled. Archives of dirs are served
+	// regardless of their index file.
+	requested, dir := fileName, stat.IsDir()
+	if dir && s.archives && r.URL.Query().Has("archive") {
+		s.serveArchive(w, r, fileName, r.URL.Query().Get("archive"))
+		return
+	}
+	if dir {
+		indexName, index, indexStat, err := s.openIndex(fileName)
+		if errors.Is(err, ErrFileNotFound) && s.listing {
+			if target := s.canonicalRedirect(requested, dir, trailingSlash); target != "" {
+				redirect(w, r, target)
+				return
+			}
+			s.serveListing(w, r, fileName, trailingSlash)
+			return
+		}
+		if err != nil {
+			s.errHandler(w, r, err)
+			return
+		}
+		defer index.Close()
+		fileName, file, stat = indexName, index, indexStat
+	}
+	if target := s.canonicalRedirect(requested, dir, trailingSlash); target != "" {
+		redirect(w, r, target)
+		return
+	}
+
+	s.serveFile(w, r, fileName, file, stat, false)
+}
+
+// Serves the file name, which was already opened and stat'ed. fallback reports whether the file is
+// served in place of the requested one, such as the fallback file of a SPA.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, fileName string, file fs.File, stat fs.FileInfo, fallback bool) {
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusInternalServerError, Op: "seek", Path: fileName, Err: errNotSeeker})
 		return
 	}
 
-	content := file.(io.ReadSeeker)
+	// Add 'Accept-Encoding' Vary header
+	addVary(w.Header(), "Accept-Encoding")
+	if s.dictionaries != nil {
+		addVary(w.Header(), "Available-Dictionary")
+	}
+
 	// Calculate ETag
-	if s.etagFn != nil {
-		etag, err := s.etagFn(content)
+	//
+	// The ETag identifies the file contents. Each encoded representation of the file gets its own
+	// ETag derived from it, see [encodedETag].
+	//
+	// The digests of the file are calculated along with it, so the file is read only once.
+	var hashes fileHashes
+	if s.etagFn != nil || s.fileETagFn != nil || s.manifest != nil || len(s.digests) > 0 {
+		var err error
+		hashes, err = s.hashFile(fileName, stat, content, true, s.digests)
 		if err != nil {
-			s.errHandler(w, r, fmt.Errorf("failed to calculate etag: %w", err))
+			s.errHandler(w, r, err)
 			return
 		}
-		if _, err := content.Seek(0, io.SeekStart); err != nil {
-			s.errHandler(w, r, fmt.Errorf("failed to seek content: %w", err))
+	}
+	etag := hashes.etag
+
+	// Content negotiation
+	//
+	// Every encoding the server is able to produce for this file is offered to the client, which
+	// picks the preferred one through the Accept-Encoding header.
+	accept := parseAcceptEncoding(r)
+
+	// Precompressed sidecar files (app.js.br, app.js.gz, ...)
+	//
+	// When a sidecar accepted by the client exists, it's served in place of the original file,
+	// keeping the Content-Type of the original file. Sidecars are always served, regardless of
+	// the compression policy.
+	var (
+		found map[string]sidecar
+		err   error
+	)
+	if s.precompressed {
+		found, err = openSidecars(s.fs, accept, fileName, s.encodings)
+		if err != nil {
+			s.errHandler(w, r, err)
 			return
 		}
-		w.Header().Set("ETag", etag)
+		defer closeSidecars(found)
 	}
 
-	// Set Cache-Control header
-	if s.cacheControlFn != nil {
-		cacheControl := s.cacheControlFn(r)
-		if cacheControl != "" {
-			w.Header().Set("Cache-Control", cacheControl)
+	// Content-Type must be detected from the uncompressed content.
+	if err := setContentType(w, fileName, content); err != nil {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusInternalServerError, Op: "read", Path: fileName, Err: err})
+		return
+	}
+
+	// By default, the server only compresses files that are less than 15mbs in length, since it's done in memory,
+	// and should cover most assets normally served in a web application.
+	//
+	// Larger files are compressed while streamed if enabled, except for Range requests, which are better served
+	// by the uncompressed file, since streamed responses can't honor them.
+	size := stat.Size()
+	compressible := s.compressionPolicy == nil || s.compressionPolicy(fileName, w.Header().Get("Content-Type"), size)
+	inMemory := compressible && size > s.minCompress && size < s.maxCompress
+	streamed := compressible && s.streaming && size > s.minCompress && size >= s.maxCompress && r.Header.Get("Range") == ""
+
+	var offered []string
+	for _, encoding := range s.encodings {
+		if _, ok := found[encoding]; ok || inMemory || streamed {
+			offered = append(offered, encoding)
 		}
 	}
 
-	// Add 'Accept-Encoding' Vary header
-	w.Header().Add("Vary", "Accept-Encoding")
+	// Compression dictionary transport
+	//
+	// When the client holds a dictionary known to the server, such as a previous version of the file,
+	// the file may be delta-compressed against it, which is preferred over any other encoding.
+	var dict *dictionary
+	if s.dictionaries != nil && inMemory && accept.accepts(dictionaryZstd) {
+		dict = s.dictionaries.lookup(r)
+		if dict != nil {
+			offered = append([]string{dictionaryZstd}, offered...)
+		}
+	}
 
-	// Compressed (gzip)
+	encoding, ok := accept.negotiate(offered)
+	if !ok {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusNotAcceptable, Op: "negotiate", Path: fileName, Err: ErrNotAcceptable})
+		return
+	}
+
+	// A Range request resuming a representation of the file in another acceptable encoding is
+	// served with that encoding, so the ranges are taken from the same bytes the client holds.
+	if resumed, ok := ifRangeEncoding(r, etag, s.encodings); ok && resumed != encoding {
+		if resumed == identity || slices.Contains(offered, resumed) {
+			if accept.accepts(resumed) {
+				encoding = resumed
+			}
+		}
+	}
+
+	// Compressed (br, zstd, gzip, ...)
 	//
 	// In early versions compression was done 'on-the-fly' by a [http.ResponseWriter] wrapper.
 	// This was bad because it was not possible to for the [http.ServeContent] function to determine
@@ -134,30 +294,311 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Not setting the Content-Length header cause all sorts of problems, like being unable to serve
 	// Range requests, enabling connection reuses, etc.
 	//
-	// For now, the server only compresses files that are less than 15mbs in length, since it's done in memory,
-	// and should cover most assets normally served in a web application.
-	if acceptsGzip(r) && (stat.Size() > 1024 && stat.Size() < maxCompressSize) {
-		buf := new(bytes.Buffer)
-		gzw := gzip.NewWriter(buf)
-
-		_, err := io.Copy(gzw, content)
+	// The representation identifies the encoded bytes. It's the encoding itself, except for dictionary
+	// compression, where the bytes also depend on the dictionary.
+	var (
+		encoded io.ReadSeeker
+		data    []byte
+	)
+	representation := encoding
+	modTime := stat.ModTime()
+	precompressed, isPrecompressed := found[encoding]
+	if isPrecompressed {
+		encoded, modTime = precompressed.file.(io.ReadSeeker), precompressed.stat.ModTime()
+	} else if encoding != identity && !streamed {
+		encoderFn := s.encoders[encoding]
+		if encoding == dictionaryZstd {
+			encoderFn, representation = dict.encoder(), dict.encoding()
+		}
+		data, err = s.compress(fileName, representation, encoderFn, etag, stat, content)
 		if err != nil {
-			s.errHandler(w, r, fmt.Errorf("fileserver: failed to compress content: %w", err))
+			s.errHandler(w, r, &Error{StatusCode: http.StatusInternalServerError, Op: "compress", Path: fileName, Err: err})
 			return
 		}
+		// Compression that doesn't save enough bytes isn't worth the client's time decompressing it.
+		if !s.worthCompressing(size, int64(len(data))) && accept.accepts(identity) {
+			encoding, representation = identity, identity
+		} else {
+			encoded = bytes.NewReader(data)
+		}
+	}
 
-		// Closes the gzip.Writer and flushes the compressed data to buf.
-		if err := gzw.Close(); err != nil {
-			s.errHandler(w, r, fmt.Errorf("fileserver: failed to close gzip writer: %w", err))
-			return
+	if etag != "" {
+		w.Header().Set("ETag", encodedETag(etag, representation))
+		// Validators of other representations are accepted in If-None-Match, since they all share
+		// the same underlying content.
+		r = withNormalizedIfNoneMatch(r, etag, encodedETag(etag, representation), s.encodings)
+	}
+
+	// Digests (RFC 9530)
+	//
+	// Repr-Digest covers the selected representation, so it's calculated over the encoded bytes of compressed
+	// responses. Content-Digest covers the response content, so it's only sent when the response carries the
+	// whole representation. Streamed responses have no digests, since their contents aren't known upfront.
+	if len(s.digests) > 0 && (encoded != nil || encoding == identity) {
+		reprAlgorithms := selectDigests(r.Header.Get("Want-Repr-Digest"), s.digests)
+		contentAlgorithms := selectDigests(r.Header.Get("Want-Content-Digest"), s.digests)
+		algorithms := append(slices.Clone(reprAlgorithms), contentAlgorithms...)
+
+		var digests map[string][]byte
+		switch {
+		case isPrecompressed:
+			hashes, err := s.hashFile(precompressed.name, precompressed.stat, encoded, false, algorithms)
+			if err != nil {
+				s.errHandler(w, r, err)
+				return
+			}
+			digests = hashes.digests
+		case encoded != nil:
+			digests = digestBytes(data, algorithms)
+		default:
+			// The digests of the file were calculated along with its ETag. The content can't be read
+			// again here, since it may have been consumed by compression.
+			digests = hashes.digests
 		}
 
-		// Set the Content-Length manually
-		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
-		w.Header().Set("Content-Encoding", "gzip")
-		http.ServeContent(w, r, fileName, stat.ModTime(), bytes.NewReader(buf.Bytes()))
-		return
+		if len(reprAlgorithms) > 0 {
+			w.Header().Set("Repr-Digest", formatDigests(digests, reprAlgorithms))
+		}
+		if len(contentAlgorithms) > 0 && r.Method == http.MethodGet {
+			w = &digestResponseWriter{ResponseWriter: w, contentDigest: formatDigests(digests, contentAlgorithms)}
+		}
+	}
+
+	if s.dictionaries != nil {
+		if useAsDictionary := s.dictionaries.useAsDictionary(fileName); useAsDictionary != "" {
+			w.Header().Set("Use-As-Dictionary", useAsDictionary)
+		}
+	}
+
+	// Set Cache-Control header
+	switch {
+	case s.cacheHeadersFn != nil:
+		s.cacheHeadersFn(r, FileMeta{
+			Path:        fileName,
+			Info:        stat,
+			ContentType: w.Header().Get("Content-Type"),
+			Fallback:    fallback,
+		}).set(w)
+	case s.cacheControlFn != nil:
+		cacheControl := s.cacheControlFn(r)
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+	}
+
+	if s.surrogateKeysFn != nil {
+		setSurrogateKeys(w, s.surrogateKeysFn(fileName))
+	}
+
+	switch {
+	case encoded != nil:
+		serveEncoded(w, r, fileName, modTime, encoded, encoding)
+	case encoding != identity:
+		s.serveStream(w, r, fileName, modTime, content, encoding)
+	default:
+		http.ServeContent(w, r, fileName, modTime, content)
+	}
+}
+
+// Returns the hashes of the file contents: its ETag, if etag is set, and the digests of the given
+// algorithms. Hashes are taken from the manifest when available. Otherwise, they're cached by the
+// file's path, size and modification time, so the file is only read when it changes, and at most once.
+func (s *Server) hashFile(name string, stat fs.FileInfo, content io.ReadSeeker, etag bool, algorithms []string) (fileHashes, error) {
+	var hashes fileHashes
+	if s.etags != nil {
+		hashes, _ = s.etags.get(name, stat)
+	}
+	if s.manifest != nil {
+		if entry, ok := s.manifest.lookup(name, stat); ok {
+			hashes = hashes.withManifest(entry)
+		}
+	}
+
+	computeETag := etag && hashes.etag == "" && (s.etagFn != nil || s.fileETagFn != nil)
+	missing := hashes.missingDigests(algorithms)
+	if !computeETag && len(missing) == 0 {
+		return hashes, nil
+	}
+
+	// The ETag function and the digests read the contents once, through the same reader.
+	hashers := make([]io.Writer, len(missing))
+	for i, algorithm := range missing {
+		hashers[i] = digestAlgorithms[algorithm]()
+	}
+	tee := io.TeeReader(content, io.MultiWriter(hashers...))
+
+	if computeETag {
+		var err error
+		if s.fileETagFn != nil {
+			hashes.etag, err = s.fileETagFn(name, stat, tee)
+		} else {
+			hashes.etag, err = s.etagFn(tee)
+		}
+		if err != nil {
+			return fileHashes{}, &Error{StatusCode: http.StatusInternalServerError, Op: "etag", Path: name, Err: err}
+		}
+	}
+	if len(missing) > 0 {
+		// The ETag function may not read the whole file.
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			return fileHashes{}, &Error{StatusCode: http.StatusInternalServerError, Op: "digest", Path: name, Err: err}
+		}
+		digests := make(map[string][]byte, len(hashes.digests)+len(missing))
+		for algorithm, digest := range hashes.digests {
+			digests[algorithm] = digest
+		}
+		for i, algorithm := range missing {
+			digests[algorithm] = hashers[i].(hash.Hash).Sum(nil)
+		}
+		hashes.digests = digests
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return fileHashes{}, &Error{StatusCode: http.StatusInternalServerError, Op: "seek", Path: name, Err: err}
+	}
+
+	if s.etags != nil {
+		s.etags.add(name, stat, hashes)
+	}
+	return hashes, nil
+}
+
+// Returns the ETag of the file if it's known without reading the file: cached, in the manifest, or
+// derived from the file metadata by the [FileETagFunc]. Otherwise, an empty string is returned.
+func (s *Server) knownETag(name string, stat fs.FileInfo) string {
+	var hashes fileHashes
+	if s.etags != nil {
+		hashes, _ = s.etags.get(name, stat)
+	}
+	if s.manifest != nil {
+		if entry, ok := s.manifest.lookup(name, stat); ok {
+			hashes = hashes.withManifest(entry)
+		}
+	}
+	if hashes.etag != "" || s.fileETagFn == nil {
+		return hashes.etag
+	}
+
+	// Functions that need the contents fail, since they can't read them.
+	etag, err := s.fileETagFn(name, stat, unreadable{})
+	if err != nil {
+		return ""
+	}
+	return etag
+}
+
+// unreadable is a reader whose contents aren't available.
+type unreadable struct{}
+
+func (unreadable) Read([]byte) (int, error) {
+	return 0, errUnreadable
+}
+
+// Removes the cached ETags of the given files, which are calculated again on the next request. If no
+// names are provided, all cached ETags are removed.
+//
+// This is only needed for [fs.FS] implementations that may change a file without changing its size or
+// modification time.
+func (s *Server) InvalidateETags(names ...string) {
+	if s.etags != nil {
+		s.etags.invalidate(names...)
+	}
+}
+
+// Compresses content into the given representation using encoderFn. Compressed contents are cached
+// in memory, since they only change with the file.
+func (s *Server) compress(name, representation string, encoderFn EncoderFunc, etag string, stat fs.FileInfo, content io.Reader) ([]byte, error) {
+	compress := func() ([]byte, error) {
+		buf := new(bytes.Buffer)
+		if err := encode(buf, content, encoderFn); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if s.cache == nil {
+		return compress()
+	}
+	return s.cache.do(compressionCacheKey(name, representation, etag, stat), compress)
+}
+
+// Reports whether compressing a file of the given size into compressedSize bytes saves at least
+// the minimum compression gain.
+func (s *Server) worthCompressing(size, compressedSize int64) bool {
+	return float64(compressedSize) <= float64(size)*(1-s.minGain)
+}
+
+// Returns the key of the compressed content of a file in the compression cache. The ETag identifies
+// the file contents when available, otherwise its size and modification time are used.
+func compressionCacheKey(name, encoding, etag string, stat fs.FileInfo) string {
+	version := etag
+	if version == "" {
+		version = strconv.FormatInt(stat.Size(), 10) + "-" + strconv.FormatInt(stat.ModTime().UnixNano(), 10)
+	}
+	return name + "\x00" + encoding + "\x00" + version
+}
+
+// Reports whether the method of r is supported, responding with an error if it isn't.
+func (s *Server) checkMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusMethodNotAllowed, Op: "method", Path: r.URL.Path, Err: ErrInvalidMethod})
+		return false
+	}
+	return true
+}
+
+// Reports whether name matches any of the deny patterns of the server, or is inside a dir that does.
+func (s *Server) denied(name string) bool {
+	if len(s.deny) == 0 || name == "." {
+		return false
+	}
+	for p := name; p != "."; p = path.Dir(p) {
+		for _, pattern := range s.deny {
+			if matchGlob(pattern, p) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	http.ServeContent(w, r, fileName, stat.ModTime(), content)
+// Reports whether name is hidden from dir listings and archives, either because it's denied or
+// because it's a dotfile and dotfiles aren't listed.
+func (s *Server) hidden(name string) bool {
+	return s.denied(name) || (!s.listDotfiles && strings.HasPrefix(path.Base(name), "."))
+}
+
+// Adds value to the Vary header, unless it's already listed, such as when an error response is written
+// after the headers of the file.
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+// Sets the Content-Type header from the extension of name. If the extension is unknown,
+// the type is detected from the first 512 bytes of content, which is then rewound.
+func setContentType(w http.ResponseWriter, name string, content io.ReadSeeker) error {
+	if w.Header().Get("Content-Type") != "" {
+		return nil
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		buf := make([]byte, 512)
+		n, err := io.ReadFull(content, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		contentType = http.DetectContentType(buf[:n])
+	}
+	w.Header().Set("Content-Type", contentType)
+	return nil
 }
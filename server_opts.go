@@ -1,19 +1,42 @@
 package fileserver
 
+import (
+	"html/template"
+	"io/fs"
+	"slices"
+)
+
 type ServerOptFn func(s *Server)
 
-// Adds a custom ETag function to the server.
+// Adds a custom ETag function to the server. Built-in options are [MD5ETag] (the default),
+// [SHA256ETag], [XXHashETag] and [FNVETag]. If a nil function is provided, the server won't
+// set the ETag header.
 func WithETagFunc(etagFn ETagFunc) ServerOptFn {
 	return func(s *Server) {
 		s.etagFn = etagFn
+		s.fileETagFn = nil
+	}
+}
+
+// Adds a custom ETag function to the server that also receives the file path and [fs.FileInfo],
+// such as [WeakETag]. It replaces any function set by [WithETagFunc].
+//
+//	fileServer := New(myFS, WithFileETagFunc(WeakETag))
+func WithFileETagFunc(etagFn FileETagFunc) ServerOptFn {
+	return func(s *Server) {
+		s.fileETagFn = etagFn
+		s.etagFn = nil
 	}
 }
 
 // Adds a custom error handler function to the server that's called
-// whenever an error happens.
+// whenever an error happens. Errors are an [*Error], holding the status code of the response
+// along with the failed operation and its cause.
 //
-// By default, an 404 response is sent for [ErrFileNotFound], a 405 response is sent to [ErrInvalidMethod]
-// and a 400 response is sent to [ErrInvalidPath]. For unknown errors, the server responds with a 500 Internal Server Error response.
+// By default, an 404 response is sent for [ErrFileNotFound], a 405 response is sent to [ErrInvalidMethod],
+// a 406 response is sent to [ErrNotAcceptable] and a 400 response is sent to [ErrInvalidPath]. For unknown errors, the server responds with a 500 Internal Server Error response.
+//
+// See [NegotiatingErrorHandler] for a handler that also responds with HTML and problem details.
 func WithErrorHandler(errHandler ErrorHandlerFunc) ServerOptFn {
 	return func(s *Server) {
 		s.errHandler = errHandler
@@ -26,5 +49,307 @@ func WithErrorHandler(errHandler ErrorHandlerFunc) ServerOptFn {
 func WithCacheControlFunc(cacheControlFn CacheControlFunc) ServerOptFn {
 	return func(s *Server) {
 		s.cacheControlFn = cacheControlFn
+		s.cacheHeadersFn = nil
+	}
+}
+
+// Adds a custom function for the caching headers of responses, which receives the metadata of the served
+// file, such as its size and content type, and can set the 'Cache-Control', 'Expires', 'CDN-Cache-Control'
+// and 'Surrogate-Control' headers together.
+//
+// This function replaces the one set by [WithCacheControlFunc], and vice versa.
+func WithCacheHeadersFunc(cacheHeadersFn CacheHeadersFunc) ServerOptFn {
+	return func(s *Server) {
+		s.cacheHeadersFn = cacheHeadersFn
+		s.cacheControlFn = nil
+	}
+}
+
+// Enables or disables serving precompressed sidecar files. When enabled, which is the default,
+// a request for 'app.js' is served from 'app.js.br', 'app.js.zst' or 'app.js.gz' if the file
+// exists in the same [fs.FS] and the client accepts its encoding.
+func WithPrecompressed(enabled bool) ServerOptFn {
+	return func(s *Server) {
+		s.precompressed = enabled
+	}
+}
+
+// Registers an encoder used to compress responses on-the-fly with the given content-coding, such as
+// "br", "zstd" or "gzip", replacing the built-in one if present. New encodings are preferred over
+// gzip, which remains the fallback.
+//
+// If a nil function is provided, the encoding is disabled and neither compressed on-the-fly
+// nor served from precompressed files.
+func WithEncoder(encoding string, encoderFn EncoderFunc) ServerOptFn {
+	return func(s *Server) {
+		i := slices.Index(s.encodings, encoding)
+		switch {
+		case encoderFn == nil:
+			if i >= 0 {
+				s.encodings = slices.Delete(s.encodings, i, i+1)
+			}
+			delete(s.encoders, encoding)
+			return
+		case i < 0:
+			if j := slices.Index(s.encodings, "gzip"); j >= 0 {
+				s.encodings = slices.Insert(s.encodings, j, encoding)
+			} else {
+				s.encodings = append(s.encodings, encoding)
+			}
+		}
+		s.encoders[encoding] = encoderFn
+	}
+}
+
+// Sets the memory budget, in bytes, of the in-memory LRU cache of compressed file contents.
+// Entries are keyed by the file path, encoding and ETag (or size and modification time if ETags
+// are disabled), so a changed file is compressed again.
+//
+// The default budget is 32mb. If a size of 0 or less is provided, the cache is disabled.
+func WithCompressionCache(maxSize int64) ServerOptFn {
+	return func(s *Server) {
+		if maxSize <= 0 {
+			s.cache = nil
+			return
+		}
+		s.cache = newCompressionCache(maxSize)
+	}
+}
+
+// Sets the size limits, in bytes, of files compressed on-the-fly. Only files larger than minSize and
+// smaller than maxSize are compressed, since compression is done in memory. The defaults are 1kb and 15mb.
+//
+// Files larger than maxSize can still be compressed by enabling [WithStreamingCompression].
+func WithCompressionLimits(minSize, maxSize int64) ServerOptFn {
+	return func(s *Server) {
+		s.minCompress = minSize
+		s.maxCompress = maxSize
+	}
+}
+
+// Enables compressing files too large to be compressed in memory while they're streamed to the client.
+//
+// Since the compressed length isn't known upfront, streamed responses are sent without a Content-Length
+// header and don't support Range requests. Range requests for these files are served uncompressed instead.
+func WithStreamingCompression(enabled bool) ServerOptFn {
+	return func(s *Server) {
+		s.streaming = enabled
+	}
+}
+
+// Sets the policy that decides whether a file is compressed on-the-fly. The default, [DefaultCompressionPolicy],
+// skips files with content types that are already compressed, such as images, videos and archives. If a nil
+// policy is provided, all files within the compression limits are compressed.
+//
+// Precompressed sidecar files are served regardless of the policy.
+func WithCompressionPolicy(policy CompressionPolicy) ServerOptFn {
+	return func(s *Server) {
+		s.compressionPolicy = policy
+	}
+}
+
+// Sets the minimum fraction of bytes compression must save for a compressed response to be served. When
+// compressing a file saves less than that, the file is served uncompressed instead, unless the client
+// doesn't accept it. The default is [DefaultMinCompressionGain], and a gain of 0 disables the check.
+//
+// The gain can't be checked for streamed responses, see [WithStreamingCompression].
+func WithMinCompressionGain(gain float64) ServerOptFn {
+	return func(s *Server) {
+		s.minGain = gain
+	}
+}
+
+// Enables Compression Dictionary Transport (RFC 9842). Files for which match returns a URL pattern are
+// sent with the Use-As-Dictionary header, so clients keep them as dictionaries for future requests to
+// URLs matching the pattern.
+//
+// When a request advertises a dictionary through the Available-Dictionary header, the response is
+// delta-compressed against it with the 'dcz' encoding, if the client accepts it. Dictionaries are looked
+// up in the served [fs.FS] and in sources, such as the directory of a previous deploy, so clients holding
+// an older version of a file can download the new one as a small delta.
+//
+// The dictionaries are indexed by hash on first use. When a request advertises a dictionary missing from
+// the index, the index is rebuilt, at most once a minute, so files added later are found too. Sources and
+// files that can't be read are skipped, and requests are compressed without a dictionary instead.
+//
+//	fileServer := New(os.DirFS("dist"), WithDictionaries(func(name string) string {
+//		if path.Ext(name) == ".js" {
+//			return "/static/*.js"
+//		}
+//		return ""
+//	}, os.DirFS("dist.previous")))
+//
+// If a nil match function is provided, dictionary compression is disabled, which is the default.
+func WithDictionaries(match DictionaryMatchFunc, sources ...fs.FS) ServerOptFn {
+	return func(s *Server) {
+		if match == nil {
+			s.dictionaries = nil
+			return
+		}
+		s.dictionaries = newDictionaries(match, append([]fs.FS{s.fs}, sources...))
+	}
+}
+
+// Sets the maximum number of ETags kept in the in-memory LRU cache. Cached ETags are reused while the
+// size and modification time of the file don't change, so the file isn't hashed on every request.
+//
+// The default is 10000 entries. If a size of 0 or less is provided, the cache is disabled, which should
+// be done for [fs.FS] implementations with unreliable modification times. See also [Server.InvalidateETags].
+func WithETagCache(maxEntries int) ServerOptFn {
+	return func(s *Server) {
+		if maxEntries <= 0 {
+			s.etags = nil
+			return
+		}
+		s.etags = newETagCache(maxEntries)
+	}
+}
+
+// Uses the ETags of a build-time [Manifest] instead of calculating them. Files missing from the manifest,
+// or whose size doesn't match their entry, fall back to the configured ETag function.
+//
+//	manifest, err := LoadManifest(os.DirFS("dist"), "manifest.json")
+//	if err != nil {
+//		// Handle error
+//	}
+//	fileServer := New(os.DirFS("dist"), WithManifest(manifest))
+func WithManifest(m *Manifest) ServerOptFn {
+	return func(s *Server) {
+		s.manifest = m
+	}
+}
+
+// Enables the Repr-Digest and Content-Digest response headers (RFC 9530) with the given algorithms,
+// [DigestSHA256] and [DigestSHA512]. Digests of compressed responses are calculated over the compressed
+// bytes, and are cached along with ETags.
+//
+// Clients may pick a single algorithm with the Want-Repr-Digest and Want-Content-Digest request headers.
+// Content-Digest isn't sent for partial responses, and streamed responses get no digests at all.
+func WithDigests(algorithms ...string) ServerOptFn {
+	return func(s *Server) {
+		s.digests = nil
+		for _, algorithm := range algorithms {
+			if _, ok := digestAlgorithms[algorithm]; ok && !slices.Contains(s.digests, algorithm) {
+				s.digests = append(s.digests, algorithm)
+			}
+		}
+	}
+}
+
+// Sets the 'Surrogate-Key' and 'Cache-Tag' headers of responses to the keys returned by keysFn, so CDNs
+// that support tag-based purging can purge groups of files at once. See [DefaultSurrogateKeys] and
+// [ChangedSurrogateKeys].
+func WithSurrogateKeys(keysFn SurrogateKeysFunc) ServerOptFn {
+	return func(s *Server) {
+		s.surrogateKeysFn = keysFn
+	}
+}
+
+// Sets the names of the index files served for dir requests, which are tried in order. The default
+// is 'index.html'. If no names are provided, dir requests are handled as not found.
+func WithIndexFiles(names ...string) ServerOptFn {
+	return func(s *Server) {
+		s.indexFiles = names
+	}
+}
+
+// Sets the policy for redirecting requests to the canonical URL of dirs and files, see [TrailingSlash].
+// Redirects are relative, so they work regardless of where the server is mounted, and dirs are only
+// redirected if they have an index file. The default is [TrailingSlashAdd].
+func WithTrailingSlash(policy TrailingSlash) ServerOptFn {
+	return func(s *Server) {
+		s.trailingSlash = policy
+	}
+}
+
+// Enables or disables listing the contents of dirs without an index file, which is disabled by default.
+// The listing is an HTML page rendered with the template set by [WithListingTemplate].
+//
+// Entries can be sorted with the 'sort' (name, size, modtime or type) and 'order' (asc or desc) query
+// parameters, and filtered with the 'filter' query parameter, either a glob such as '*.js' or a
+// substring of the names. Clients that prefer 'application/json' get a paginated [JSONListing] instead.
+func WithDirListing(enabled bool) ServerOptFn {
+	return func(s *Server) {
+		s.listing = enabled
+	}
+}
+
+// Sets the template used to render dir listings, which is executed with a [Listing]. If nil, the
+// default template is used.
+func WithListingTemplate(tmpl *template.Template) ServerOptFn {
+	return func(s *Server) {
+		if tmpl == nil {
+			tmpl = defaultListingTemplate
+		}
+		s.listingTemplate = tmpl
+	}
+}
+
+// Sets whether dir listings include dotfiles, such as '.env', which are hidden by default. Hidden
+// files can still be requested directly.
+func WithListingDotfiles(visible bool) ServerOptFn {
+	return func(s *Server) {
+		s.listDotfiles = visible
+	}
+}
+
+// Hides files matching any of the glob patterns, with the syntax of [path.Match], which are handled as not
+// found. Patterns are matched against both the path and the base name of files and their parent dirs, so
+// '.git' hides the contents of '.git' dirs at any depth. Denied files are also left out of dir listings
+// and archives.
+//
+// Panics if a pattern is malformed.
+func WithDeny(patterns ...string) ServerOptFn {
+	for _, pattern := range patterns {
+		mustValidGlob(pattern)
+	}
+	return func(s *Server) {
+		s.deny = append(s.deny, patterns...)
+	}
+}
+
+// Enables or disables downloading dirs as archives with the 'archive' query parameter, either
+// '?archive=zip' or '?archive=tar.gz', which is disabled by default.
+//
+// Archives are streamed from the [fs.FS] without buffering them in memory. Hidden files are left out
+// of them, like in dir listings, see [WithListingDotfiles] and [WithDeny].
+func WithArchives(enabled bool) ServerOptFn {
+	return func(s *Server) {
+		s.archives = enabled
+	}
+}
+
+// Sets the maximum number of entries and the maximum total size, in bytes, of the files of dir archives.
+// Larger archives are rejected with [ErrArchiveTooLarge]. The defaults are 10000 entries and 1gb.
+func WithArchiveLimits(maxEntries int, maxSize int64) ServerOptFn {
+	return func(s *Server) {
+		s.maxArchiveEntries = maxEntries
+		s.maxArchiveSize = maxSize
+	}
+}
+
+// Serves error pages from fsys instead of plain text responses, mapping status codes to file names,
+// such as 404 to '404.html'. If fsys is nil, the pages are served from the [fs.FS] of the server.
+//
+// Pages are served with the status code of the error, like other files, including compression, but
+// with the 'Cache-Control: no-store' header. If there's no page for a status code, or it can't be
+// served, the default plain text response is sent instead.
+//
+// This option replaces the error handler set by [WithErrorHandler], and vice versa.
+func WithErrorPages(fsys fs.FS, pages map[int]string) ServerOptFn {
+	return func(s *Server) {
+		s.errHandler = (&errorPages{server: s, fsys: fsys, pages: pages}).serve
+	}
+}
+
+// Sets the function deciding whether requests for missing files are served from the fallback file of a
+// [SPAServer]. The default is [NavigationFallback], and [AlwaysFallback] serves every missing file from
+// the fallback file. If nil, the default is used. It has no effect on [Server].
+func WithSPAFallback(fallbackFn FallbackFunc) ServerOptFn {
+	return func(s *Server) {
+		if fallbackFn == nil {
+			fallbackFn = NavigationFallback()
+		}
+		s.spaFallbackFn = fallbackFn
 	}
 }
@@ -2,12 +2,17 @@ package fileserver
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestWithETagFunc(t *testing.T) {
@@ -30,6 +35,46 @@ func TestWithETagFunc(t *testing.T) {
 	}
 }
 
+func TestWithFileETagFunc(t *testing.T) {
+	var gotName string
+	h := New(os.DirFS("testdata"), WithFileETagFunc(func(name string, info fs.FileInfo, r io.Reader) (string, error) {
+		gotName = name
+		return WeakETag(name, info, r)
+	}))
+
+	srv := httptest.NewServer(http.StripPrefix("/", h))
+	client := srv.Client()
+
+	res, err := client.Get(srv.URL + "/file.txt")
+	if err != nil {
+		t.Fatalf("failed to get file from server: %s", err)
+	}
+	defer res.Body.Close()
+
+	etag := res.Header.Get("ETag")
+	if !strings.HasPrefix(etag, `W/"c-`) {
+		t.Errorf("expected weak etag but got %s", etag)
+	}
+	if gotName != "file.txt" {
+		t.Errorf("expected name to be file.txt but got %s", gotName)
+	}
+
+	// Weak ETags are compared with the weak comparison in If-None-Match.
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/file.txt", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %s", err)
+	}
+	req.Header.Set("If-None-Match", etag)
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to get file from server: %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status to be 304 but got %d", res.StatusCode)
+	}
+}
+
 func TestWithErrorHandler(t *testing.T) {
 	h := New(os.DirFS("testdata"), WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
 		w.WriteHeader(http.StatusTeapot)
@@ -133,3 +178,510 @@ func TestWithCacheControlFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestWithCacheHeadersFunc(t *testing.T) {
+	expires := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var meta FileMeta
+	h := New(os.DirFS("testdata"), WithCacheHeadersFunc(func(r *http.Request, file FileMeta) CacheHeaders {
+		meta = file
+		return CacheHeaders{
+			CacheControl:     "public, max-age=60",
+			Expires:          expires,
+			CDNCacheControl:  "max-age=3600",
+			SurrogateControl: "max-age=86400",
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subdir/subfile.txt", nil)
+	http.StripPrefix("/", h).ServeHTTP(w, r)
+
+	if meta.Path != "subdir/subfile.txt" {
+		t.Errorf("expected path to be %q but got %q", "subdir/subfile.txt", meta.Path)
+	}
+	if meta.Info == nil || meta.Info.Name() != "subfile.txt" {
+		t.Errorf("expected file info of %q but got %v", "subfile.txt", meta.Info)
+	}
+	if meta.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("expected content type to be %q but got %q", "text/plain; charset=utf-8", meta.ContentType)
+	}
+	if meta.Fallback {
+		t.Error("expected fallback to be false")
+	}
+
+	expected := map[string]string{
+		"Cache-Control":     "public, max-age=60",
+		"Expires":           "Tue, 01 Jan 2030 00:00:00 GMT",
+		"CDN-Cache-Control": "max-age=3600",
+		"Surrogate-Control": "max-age=86400",
+	}
+	for key, value := range expected {
+		if got := w.Header().Get(key); got != value {
+			t.Errorf("expected %s to be %q but got %q", key, value, got)
+		}
+	}
+}
+
+func TestWithPrecompressed(t *testing.T) {
+	h := New(os.DirFS("testdata"), WithPrecompressed(false))
+
+	srv := httptest.NewServer(http.StripPrefix("/", h))
+	client := srv.Client()
+	client.Transport = &http.Transport{
+		DisableCompression: true,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/precompressed/app.js", nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating request: %s", err)
+	}
+	req.Header.Set("Accept-Encoding", "zstd")
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to get file from server: %s", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("unexpected error reading response %s", err)
+	}
+
+	sidecar, err := os.ReadFile("testdata/precompressed/app.js.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(body, sidecar) {
+		t.Error("expected precompressed file not to be served")
+	}
+}
+
+func TestWithEncoder(t *testing.T) {
+	identityEncoder := EncoderFunc(func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+
+	testCases := []struct {
+		name           string
+		opts           []ServerOptFn
+		acceptEncoding string
+		expected       string
+	}{
+		{
+			name:           "disabled encoding",
+			opts:           []ServerOptFn{WithEncoder("br", nil)},
+			acceptEncoding: "br, gzip",
+			expected:       "gzip",
+		},
+		{
+			name:           "disabled encoding (precompressed)",
+			opts:           []ServerOptFn{WithEncoder("zstd", nil)},
+			acceptEncoding: "zstd",
+			expected:       "",
+		},
+		{
+			name:           "custom encoding",
+			opts:           []ServerOptFn{WithEncoder("custom", identityEncoder), WithMinCompressionGain(0)},
+			acceptEncoding: "gzip, custom",
+			expected:       "custom",
+		},
+		{
+			name:           "custom encoding is preferred over gzip only",
+			opts:           []ServerOptFn{WithEncoder("custom", identityEncoder), WithMinCompressionGain(0)},
+			acceptEncoding: "custom, zstd",
+			expected:       "zstd",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(os.DirFS("testdata"), tt.opts...)
+
+			srv := httptest.NewServer(http.StripPrefix("/", h))
+			client := srv.Client()
+			client.Transport = &http.Transport{
+				DisableCompression: true,
+			}
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/precompressed/app.js", nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %s", err)
+			}
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("failed to get file from server: %s", err)
+			}
+			defer res.Body.Close()
+
+			if encoding := res.Header.Get("Content-Encoding"); encoding != tt.expected {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestWithCompressionCache(t *testing.T) {
+	testCases := []struct {
+		name     string
+		maxSize  int64
+		expected int32
+	}{
+		{
+			name:     "enabled",
+			maxSize:  1 << 20,
+			expected: 1,
+		},
+		{
+			name:     "disabled",
+			maxSize:  0,
+			expected: 3,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			countingEncoder := func(w io.Writer) (io.WriteCloser, error) {
+				calls.Add(1)
+				return GzipEncoder(w)
+			}
+
+			h := New(
+				os.DirFS("testdata"),
+				WithPrecompressed(false),
+				WithEncoder("gzip", countingEncoder),
+				WithCompressionCache(tt.maxSize),
+			)
+
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/precompressed/app.js", nil)
+				r.URL.Path = "precompressed/app.js"
+				r.Header.Set("Accept-Encoding", "gzip")
+				h.ServeHTTP(w, r)
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected status to be 200 but got %d", w.Code)
+				}
+			}
+
+			if n := calls.Load(); n != tt.expected {
+				t.Errorf("expected encoder to be called %d times but got %d", tt.expected, n)
+			}
+		})
+	}
+}
+
+func TestWithCompressionLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		minSize  int64
+		maxSize  int64
+		expected string
+	}{
+		{
+			name:     "within limits",
+			minSize:  0,
+			maxSize:  1 << 20,
+			expected: "gzip",
+		},
+		{
+			name:     "too small",
+			minSize:  1 << 20,
+			maxSize:  2 << 20,
+			expected: "",
+		},
+		{
+			name:     "too large",
+			minSize:  0,
+			maxSize:  100,
+			expected: "",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(os.DirFS("testdata"), WithPrecompressed(false), WithCompressionLimits(tt.minSize, tt.maxSize))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/precompressed/app.js", nil)
+			r.URL.Path = "precompressed/app.js"
+			r.Header.Set("Accept-Encoding", "gzip")
+			h.ServeHTTP(w, r)
+
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tt.expected {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestWithStreamingCompression(t *testing.T) {
+	h := New(
+		os.DirFS("testdata"),
+		WithPrecompressed(false),
+		WithCompressionLimits(0, 100),
+		WithStreamingCompression(true),
+	)
+	srv := httptest.NewServer(http.StripPrefix("/", h))
+
+	original, err := os.ReadFile("testdata/precompressed/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		header   http.Header
+		status   int
+		encoding string
+		ranges   string
+	}{
+		{
+			name:     "streamed",
+			header:   http.Header{"Accept-Encoding": {"gzip"}},
+			status:   http.StatusOK,
+			encoding: "gzip",
+			ranges:   "none",
+		},
+		{
+			name:     "range request is not streamed",
+			header:   http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}},
+			status:   http.StatusPartialContent,
+			encoding: "",
+			ranges:   "bytes",
+		},
+		{
+			name:     "not modified",
+			header:   http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {"*"}},
+			status:   http.StatusNotModified,
+			encoding: "gzip",
+			ranges:   "none",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := srv.Client()
+			client.Transport = &http.Transport{
+				DisableCompression: true,
+			}
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/precompressed/app.js", nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %s", err)
+			}
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error making request: %s", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, res.StatusCode)
+			}
+			if encoding := res.Header.Get("Content-Encoding"); encoding != tt.encoding {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.encoding, encoding)
+			}
+			if ranges := res.Header.Get("Accept-Ranges"); ranges != tt.ranges {
+				t.Errorf("expected Accept-Ranges to be %q but got %q", tt.ranges, ranges)
+			}
+
+			if tt.status == http.StatusOK {
+				gzr, err := gzip.NewReader(res.Body)
+				if err != nil {
+					t.Fatalf("unexpected error creating gzip reader: %s", err)
+				}
+				body, err := io.ReadAll(gzr)
+				if err != nil {
+					t.Fatalf("unexpected error reading response: %s", err)
+				}
+				if !bytes.Equal(body, original) {
+					t.Error("mismatched content")
+				}
+			}
+		})
+	}
+}
+
+func TestWithCompressionPolicy(t *testing.T) {
+	var (
+		gotName        string
+		gotContentType string
+		gotSize        int64
+	)
+	h := New(os.DirFS("testdata"), WithPrecompressed(false), WithCompressionPolicy(func(name, contentType string, size int64) bool {
+		gotName, gotContentType, gotSize = name, contentType, size
+		return false
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/precompressed/app.js", nil)
+	r.URL.Path = "precompressed/app.js"
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(w, r)
+
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected Content-Encoding to be empty but got %s", encoding)
+	}
+	if gotName != "precompressed/app.js" {
+		t.Errorf("expected name to be precompressed/app.js but got %s", gotName)
+	}
+	if gotContentType != "text/javascript; charset=utf-8" {
+		t.Errorf("expected content type to be text/javascript; charset=utf-8 but got %s", gotContentType)
+	}
+	if gotSize != 4000 {
+		t.Errorf("expected size to be 4000 but got %d", gotSize)
+	}
+}
+
+func TestWithMinCompressionGain(t *testing.T) {
+	testCases := []struct {
+		name           string
+		gain           float64
+		acceptEncoding string
+		expected       string
+	}{
+		{
+			name:           "enough gain",
+			gain:           0.5,
+			acceptEncoding: "gzip",
+			expected:       "gzip",
+		},
+		{
+			name:           "not enough gain",
+			gain:           0.99,
+			acceptEncoding: "gzip",
+			expected:       "",
+		},
+		{
+			name:           "not enough gain (identity not acceptable)",
+			gain:           0.99,
+			acceptEncoding: "gzip, identity;q=0",
+			expected:       "gzip",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(os.DirFS("testdata"), WithPrecompressed(false), WithMinCompressionGain(tt.gain))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/precompressed/app.js", nil)
+			r.URL.Path = "precompressed/app.js"
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status to be 200 but got %d", w.Code)
+			}
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tt.expected {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.expected, encoding)
+			}
+			if tt.expected == "" && w.Body.Len() != 4000 {
+				t.Errorf("expected uncompressed body but got %d bytes", w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestWithETagCache(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxEntries int
+		expected   int32
+	}{
+		{
+			name:       "enabled",
+			maxEntries: 10,
+			expected:   1,
+		},
+		{
+			name:       "disabled",
+			maxEntries: 0,
+			expected:   3,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			countingETagFunc := func(r io.Reader) (string, error) {
+				calls.Add(1)
+				return calculateETag(r)
+			}
+
+			h := New(os.DirFS("testdata"), WithETagFunc(countingETagFunc), WithETagCache(tt.maxEntries))
+
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+				r.URL.Path = "file.txt"
+				h.ServeHTTP(w, r)
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected status to be 200 but got %d", w.Code)
+				}
+			}
+
+			if n := calls.Load(); n != tt.expected {
+				t.Errorf("expected etag func to be called %d times but got %d", tt.expected, n)
+			}
+
+			// Invalidated ETags are calculated again.
+			h.InvalidateETags("file.txt")
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+			r.URL.Path = "file.txt"
+			h.ServeHTTP(w, r)
+			if n := calls.Load(); n != tt.expected+1 {
+				t.Errorf("expected etag func to be called %d times but got %d", tt.expected+1, n)
+			}
+		})
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestWithDeny(t *testing.T) {
+	h := http.StripPrefix("/", New(fstest.MapFS{
+		"docs/a.txt":        {Data: []byte("a")},
+		"docs/nested/b.txt": {Data: []byte("bb")},
+		"docs/secret.key":   {Data: []byte("key")},
+	}, WithDeny("*.key", "nested")))
+
+	testCases := []struct {
+		target string
+		status int
+	}{
+		{target: "/docs/a.txt", status: http.StatusOK},
+		{target: "/docs/secret.key", status: http.StatusNotFound},
+		{target: "/docs/nested/b.txt", status: http.StatusNotFound},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package fileserver
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net/http"
@@ -101,6 +102,18 @@ func TestServer(t *testing.T) {
 				return http.NewRequest(http.MethodGet, srv.URL+"/static/./server.go", nil)
 			},
 		},
+		{
+			name:   "not acceptable (identity excluded)",
+			status: http.StatusNotAcceptable,
+			newRequest: func() (*http.Request, error) {
+				req, err := http.NewRequest(http.MethodGet, srv.URL+"/static/file.txt", nil)
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Accept-Encoding", "gzip, identity;q=0")
+				return req, nil
+			},
+		},
 	}
 
 	for _, tt := range testCases {
@@ -119,8 +132,11 @@ func TestServer(t *testing.T) {
 				tt.etagFunc = calculateETag
 			}
 			h.etagFn = tt.etagFunc
+			// Cached ETags were calculated by the previous function.
+			h.InvalidateETags()
 			t.Cleanup(func() {
 				h.etagFn = calculateETag
+				h.InvalidateETags()
 			})
 
 			req, err := tt.newRequest()
@@ -172,3 +188,256 @@ func TestServe(t *testing.T) {
 		t.Errorf("expected status to be 200 but got %d", res.StatusCode)
 	}
 }
+
+func TestServerPrecompressed(t *testing.T) {
+	h := New(os.DirFS("testdata"))
+	srv := httptest.NewServer(http.StripPrefix("/", h))
+
+	// Avoid the default transport transparently decompressing gzip responses.
+	client := srv.Client()
+	client.Transport = &http.Transport{
+		DisableCompression: true,
+	}
+
+	testCases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		contentFile    string
+		encoding       string
+		contentType    string
+	}{
+		{
+			name:           "zstd preferred over gzip",
+			path:           "/precompressed/app.js",
+			acceptEncoding: "gzip, zstd",
+			contentFile:    "testdata/precompressed/app.js.zst",
+			encoding:       "zstd",
+			contentType:    "text/javascript; charset=utf-8",
+		},
+		{
+			name:           "gzip",
+			path:           "/precompressed/app.js",
+			acceptEncoding: "gzip",
+			contentFile:    "testdata/precompressed/app.js.gz",
+			encoding:       "gzip",
+			contentType:    "text/javascript; charset=utf-8",
+		},
+		{
+			name:           "no accepted encoding",
+			path:           "/precompressed/app.js",
+			acceptEncoding: "deflate",
+			contentFile:    "testdata/precompressed/app.js",
+			encoding:       "",
+			contentType:    "text/javascript; charset=utf-8",
+		},
+		{
+			name:           "sniffed content type",
+			path:           "/precompressed/data.unknownext",
+			acceptEncoding: "gzip",
+			contentFile:    "testdata/precompressed/data.unknownext.gz",
+			encoding:       "gzip",
+			contentType:    "text/html; charset=utf-8",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := os.ReadFile(tt.contentFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("unexpected error creating request: %s", err)
+			}
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error making request: %s", err)
+			}
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("unexpected error reading response: %s", err)
+			}
+
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected status to be 200 but got %d", res.StatusCode)
+			}
+			if !bytes.Equal(body, expected) {
+				t.Errorf("expected body to match %s", tt.contentFile)
+			}
+			if encoding := res.Header.Get("Content-Encoding"); encoding != tt.encoding {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.encoding, encoding)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("expected Content-Type to be %q but got %q", tt.contentType, contentType)
+			}
+			if res.ContentLength != int64(len(expected)) {
+				t.Errorf("expected Content-Length to be %d but got %d", len(expected), res.ContentLength)
+			}
+		})
+	}
+}
+
+func TestServerRangeCompressed(t *testing.T) {
+	h := New(os.DirFS("testdata"), WithPrecompressed(false))
+	srv := httptest.NewServer(http.StripPrefix("/", h))
+	client := srv.Client()
+	client.Transport = &http.Transport{
+		DisableCompression: true,
+	}
+
+	original, err := os.ReadFile("testdata/precompressed/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(t *testing.T, header http.Header) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/precompressed/app.js", nil)
+		if err != nil {
+			t.Fatalf("unexpected error creating request: %s", err)
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error making request: %s", err)
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("unexpected error reading response: %s", err)
+		}
+		return res, body
+	}
+
+	// Full compressed and uncompressed representations.
+	res, compressed := do(t, http.Header{"Accept-Encoding": {"gzip"}})
+	gzipETag := res.Header.Get("ETag")
+	res, _ = do(t, http.Header{"Accept-Encoding": {"identity"}})
+	identityETag := res.Header.Get("ETag")
+
+	if gzipETag != encodedETag(identityETag, "gzip") {
+		t.Fatalf("expected gzip etag to be derived from %s but got %s", identityETag, gzipETag)
+	}
+
+	testCases := []struct {
+		name     string
+		header   http.Header
+		status   int
+		encoding string
+		etag     string
+		body     []byte
+	}{
+		{
+			name: "range",
+			header: http.Header{
+				"Accept-Encoding": {"gzip"},
+				"Range":           {"bytes=10-19"},
+			},
+			status:   http.StatusPartialContent,
+			encoding: "gzip",
+			etag:     gzipETag,
+			body:     compressed[10:20],
+		},
+		{
+			name: "if-range (gzip)",
+			header: http.Header{
+				"Accept-Encoding": {"gzip"},
+				"Range":           {"bytes=10-19"},
+				"If-Range":        {gzipETag},
+			},
+			status:   http.StatusPartialContent,
+			encoding: "gzip",
+			etag:     gzipETag,
+			body:     compressed[10:20],
+		},
+		{
+			name: "if-range (identity)",
+			header: http.Header{
+				"Accept-Encoding": {"gzip"},
+				"Range":           {"bytes=10-19"},
+				"If-Range":        {identityETag},
+			},
+			status:   http.StatusPartialContent,
+			encoding: "",
+			etag:     identityETag,
+			body:     original[10:20],
+		},
+		{
+			name: "if-range (identity not acceptable)",
+			header: http.Header{
+				"Accept-Encoding": {"gzip, identity;q=0"},
+				"Range":           {"bytes=10-19"},
+				"If-Range":        {identityETag},
+			},
+			status:   http.StatusOK,
+			encoding: "gzip",
+			etag:     gzipETag,
+			body:     compressed,
+		},
+		{
+			name: "if-range (stale)",
+			header: http.Header{
+				"Accept-Encoding": {"gzip"},
+				"Range":           {"bytes=10-19"},
+				"If-Range":        {`"stale"`},
+			},
+			status:   http.StatusOK,
+			encoding: "gzip",
+			etag:     gzipETag,
+			body:     compressed,
+		},
+		{
+			name: "if-none-match (identity form)",
+			header: http.Header{
+				"Accept-Encoding": {"gzip"},
+				"If-None-Match":   {identityETag},
+			},
+			status:   http.StatusNotModified,
+			encoding: "gzip",
+			etag:     gzipETag,
+			body:     []byte{},
+		},
+		{
+			name: "if-none-match (gzip form)",
+			header: http.Header{
+				"Accept-Encoding": {"identity"},
+				"If-None-Match":   {gzipETag},
+			},
+			status:   http.StatusNotModified,
+			encoding: "",
+			etag:     identityETag,
+			body:     []byte{},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := do(t, tt.header)
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, res.StatusCode)
+			}
+			if encoding := res.Header.Get("Content-Encoding"); encoding != tt.encoding {
+				t.Errorf("expected Content-Encoding to be %q but got %q", tt.encoding, encoding)
+			}
+			if etag := res.Header.Get("ETag"); etag != tt.etag {
+				t.Errorf("expected ETag to be %s but got %s", tt.etag, etag)
+			}
+			if !bytes.Equal(body, tt.body) {
+				t.Error("mismatched content")
+			}
+			if res.StatusCode != http.StatusNotModified && res.ContentLength != int64(len(tt.body)) {
+				t.Errorf("expected Content-Length to be %d but got %d", len(tt.body), res.ContentLength)
+			}
+		})
+	}
+}
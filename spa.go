@@ -4,47 +4,167 @@ import (
 	"errors"
 	"io/fs"
 	"net/http"
-
+	"net/url"
+	"path"
 	"slices"
+	"strings"
 )
 
-// Creates a new [http.Handler] suitable for serving Single-Page Applications.
+// SPAServer is an [http.Handler] suitable for serving Single-Page Applications. It's created with [NewSPA]
+// and supports the same options as [Server], sharing its error handling.
+type SPAServer struct {
+	server   *Server
+	fallback string
+}
+
+// Creates a new [SPAServer].
 //
 // For the cases that a file is not found in [fs.FS], the path is invalid or the path is a dir, the server
-// will instead serve the fallback file, which in most cases should be 'index.html' or '200.html'.
-func ServeSPA(spa fs.FS, fallback string, opts ...ServerOptFn) http.Handler {
+// will instead serve the fallback file, which in most cases should be 'index.html' or '200.html'. By default,
+// only navigation requests are served from the fallback, see [NavigationFallback] and [WithSPAFallback].
+func NewSPA(spa fs.FS, fallback string, opts ...ServerOptFn) *SPAServer {
 	opts = slices.Insert(opts, 0, WithCacheControlFunc(Immutable(fallback)))
+	return &SPAServer{
+		server:   New(spa, opts...),
+		fallback: fallback,
+	}
+}
 
-	h := New(spa, opts...)
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		target := r.URL.Path
-		if target == "" {
-			target = fallback
-		}
+// Creates a new [http.Handler] suitable for serving Single-Page Applications, see [NewSPA].
+func ServeSPA(spa fs.FS, fallback string, opts ...ServerOptFn) http.Handler {
+	return NewSPA(spa, fallback, opts...)
+}
+
+func (s *SPAServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.server.checkMethod(w, r) {
+		return
+	}
 
-		f, err := spa.Open(target)
+	target := r.URL.Path
+	if target == "" {
+		target = s.fallback
+	}
+
+	// The requested file is opened once, and served unless it's missing, in which case the fallback
+	// file is served instead.
+	file, stat, err := s.open(target)
+	if err != nil {
+		s.server.errHandler(w, r, err)
+		return
+	}
+	fallback := file == nil && target != s.fallback
+	if fallback {
+		// Whether a missing file is served from the fallback depends on the shape of the request.
+		addVary(w.Header(), "Accept")
+		addVary(w.Header(), "Sec-Fetch-Mode")
+		if !s.server.spaFallbackFn(r) {
+			s.server.errHandler(w, r, fsError("open", target, fs.ErrNotExist))
+			return
+		}
+	}
+	if file == nil {
+		target = s.fallback
+		file, stat, err = s.open(target)
 		if err != nil {
-			switch {
-			case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
-				target = fallback
-			default:
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-		} else {
-			defer f.Close()
+			s.server.errHandler(w, r, err)
+			return
+		}
+		if file == nil {
+			s.server.errHandler(w, r, fsError("open", target, fs.ErrNotExist))
+			return
+		}
+	}
+	defer file.Close()
 
-			stat, err := f.Stat()
-			if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				return
-			}
-			if stat.IsDir() {
-				target = fallback
-			}
+	// Cache-Control functions identify the served file by the path of the request.
+	fr := new(http.Request)
+	*fr = *r
+	fr.URL = new(url.URL)
+	*fr.URL = *r.URL
+	fr.URL.Path = target
+	fr.URL.RawPath = ""
+
+	s.server.serveFile(w, fr, target, file, stat, fallback)
+}
+
+// Opens the file name for serving. A nil file is returned if it doesn't exist, its path is invalid, it's
+// denied or it's a dir, so the fallback file is served instead.
+func (s *SPAServer) open(name string) (fs.File, fs.FileInfo, error) {
+	if s.server.denied(name) {
+		return nil, nil, nil
+	}
+	f, err := s.server.fs.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			return nil, nil, nil
 		}
+		return nil, nil, fsError("open", name, err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fsError("stat", name, err)
+	}
+	if stat.IsDir() {
+		f.Close()
+		return nil, nil, nil
+	}
+	return f, stat, nil
+}
 
-		r.URL.Path = target
-		h.ServeHTTP(w, r)
-	})
+// FallbackFunc reports whether a request for a missing file is served from the fallback file of a
+// [SPAServer]. Otherwise, the request is handled as not found.
+type FallbackFunc func(r *http.Request) bool
+
+// Serves every request for a missing file from the fallback file.
+func AlwaysFallback(_ *http.Request) bool {
+	return true
+}
+
+// Creates a [FallbackFunc] that only serves navigation requests from the fallback file, so requests for
+// missing assets, such as '/assets/app.old.js', get a 404 response instead of an HTML page.
+//
+// A request is a navigation if its path has no extension, or one of exts, and:
+//
+//   - Its 'Sec-Fetch-Mode' header is 'navigate', if sent;
+//   - Otherwise, its 'Accept' header accepts 'text/html' explicitly, if sent.
+//
+// If no extensions are provided, '.html' and '.htm' are allowed.
+func NavigationFallback(exts ...string) FallbackFunc {
+	if len(exts) == 0 {
+		exts = []string{".html", ".htm"}
+	}
+	allowed := make([]string, len(exts))
+	for i, ext := range exts {
+		allowed[i] = "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+	}
+
+	return func(r *http.Request) bool {
+		if ext := path.Ext(r.URL.Path); ext != "" && !slices.Contains(allowed, strings.ToLower(ext)) {
+			return false
+		}
+		if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+			return mode == "navigate"
+		}
+		if values := r.Header.Values("Accept"); len(values) > 0 {
+			return acceptsHTML(values)
+		}
+		return true
+	}
+}
+
+// Reports whether the Accept header values accept 'text/html' explicitly, through 'text/html' or 'text/*'.
+func acceptsHTML(values []string) bool {
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			mediaRange, params, _ := strings.Cut(member, ";")
+			switch strings.ToLower(strings.TrimSpace(mediaRange)) {
+			case "text/html", "text/*":
+				if q, ok := parseQuality(params); ok && q > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
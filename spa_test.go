@@ -2,10 +2,15 @@ package fileserver
 
 import (
 	"bytes"
+	"errors"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestServeSPA(t *testing.T) {
@@ -83,3 +88,217 @@ func TestServeSPA(t *testing.T) {
 	}
 
 }
+
+func TestServeSPAFallbackMeta(t *testing.T) {
+	var fallback bool
+	spa := os.DirFS("testdata/spa")
+	h := http.StripPrefix("/", ServeSPA(spa, "index.html", WithCacheHeadersFunc(func(r *http.Request, file FileMeta) CacheHeaders {
+		fallback = file.Fallback
+		return CacheHeaders{}
+	})))
+
+	testCases := []struct {
+		path     string
+		fallback bool
+	}{
+		{path: "/index.html", fallback: false},
+		{path: "/assets/app.js", fallback: false},
+		{path: "/bogus", fallback: true},
+		{path: "/assets", fallback: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			h.ServeHTTP(w, r)
+
+			if fallback != tt.fallback {
+				t.Errorf("expected fallback to be %t but got %t", tt.fallback, fallback)
+			}
+		})
+	}
+}
+
+// countingFS counts the times each file is opened, failing to open the ones in fail.
+type countingFS struct {
+	fs.FS
+	opens map[string]int
+	fail  map[string]error
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opens[name]++
+	if err, ok := c.fail[name]; ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return c.FS.Open(name)
+}
+
+func TestSPAServer(t *testing.T) {
+	fsys := &countingFS{
+		FS:    os.DirFS("testdata/spa"),
+		opens: make(map[string]int),
+		fail:  map[string]error{"secret.txt": fs.ErrPermission},
+	}
+
+	var handled error
+	h := http.StripPrefix("/", NewSPA(fsys, "index.html", WithPrecompressed(false), WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	t.Run("opens once", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/assets/app.js", nil)
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status to be %d but got %d", http.StatusOK, w.Code)
+		}
+		if opens := fsys.opens["assets/app.js"]; opens != 1 {
+			t.Errorf("expected file to be opened once but got %d", opens)
+		}
+		if r.URL.Path != "/assets/app.js" {
+			t.Errorf("expected request path to be left unchanged but got %q", r.URL.Path)
+		}
+	})
+
+	t.Run("error handler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/secret.txt", nil)
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusTeapot {
+			t.Fatalf("expected status to be %d but got %d", http.StatusTeapot, w.Code)
+		}
+		var e *Error
+		if !errors.As(handled, &e) || e.StatusCode != http.StatusForbidden || !errors.Is(e, fs.ErrPermission) {
+			t.Errorf("unexpected error %v", handled)
+		}
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/bogus", nil)
+		h.ServeHTTP(w, r)
+
+		if w.Code != http.StatusTeapot {
+			t.Fatalf("expected status to be %d but got %d", http.StatusTeapot, w.Code)
+		}
+		if !errors.Is(handled, ErrInvalidMethod) {
+			t.Errorf("expected error to be %v but got %v", ErrInvalidMethod, handled)
+		}
+	})
+}
+
+func TestNavigationFallback(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		exts     []string
+		header   http.Header
+		expected bool
+	}{
+		{name: "no headers", path: "/about", expected: true},
+		{name: "missing asset", path: "/assets/app.old.js", expected: false},
+		{name: "missing asset (navigation)", path: "/assets/app.old.js", header: http.Header{"Sec-Fetch-Mode": {"navigate"}}, expected: false},
+		{name: "html extension", path: "/about.html", expected: true},
+		{name: "allowed extension", path: "/about.php", exts: []string{"php"}, expected: true},
+		{name: "navigate", path: "/about", header: http.Header{"Sec-Fetch-Mode": {"navigate"}, "Accept": {"*/*"}}, expected: true},
+		{name: "fetch", path: "/about", header: http.Header{"Sec-Fetch-Mode": {"cors"}, "Accept": {"text/html"}}, expected: false},
+		{name: "accept html", path: "/about", header: http.Header{"Accept": {"text/html,application/xhtml+xml,*/*;q=0.8"}}, expected: true},
+		{name: "accept anything", path: "/about", header: http.Header{"Accept": {"*/*"}}, expected: false},
+		{name: "html rejected", path: "/about", header: http.Header{"Accept": {"text/html;q=0, application/json"}}, expected: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			if result := NavigationFallback(tt.exts...)(r); result != tt.expected {
+				t.Errorf("expected result to be %t but got %t", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestServeSPAFallbackNavigation(t *testing.T) {
+	index, err := os.ReadFile("testdata/spa/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		opts   []ServerOptFn
+		path   string
+		accept string
+		status int
+	}{
+		{name: "navigation", path: "/settings", accept: "text/html", status: http.StatusOK},
+		{name: "missing asset", path: "/assets/app.old.js", accept: "*/*", status: http.StatusNotFound},
+		{name: "existing asset", path: "/assets/app.js", accept: "*/*", status: http.StatusOK},
+		{name: "always", opts: []ServerOptFn{WithSPAFallback(AlwaysFallback)}, path: "/assets/app.old.js", status: http.StatusOK},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.StripPrefix("/", ServeSPA(os.DirFS("testdata/spa"), "index.html", tt.opts...))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status to be %d but got %d", tt.status, w.Code)
+			}
+			if tt.name != "existing asset" && tt.status == http.StatusOK && !bytes.Equal(w.Body.Bytes(), index) {
+				t.Error("expected fallback content")
+			}
+		})
+	}
+}
+
+func TestServeSPAFallbackVary(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []ServerOptFn
+	}{
+		{name: "default error handler"},
+		{
+			name: "error pages",
+			opts: []ServerOptFn{WithErrorPages(fstest.MapFS{"404.html": {Data: []byte("not found")}}, map[int]string{http.StatusNotFound: "404.html"})},
+		},
+		{
+			name: "negotiating error handler",
+			opts: []ServerOptFn{WithErrorHandler(NegotiatingErrorHandler)},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.StripPrefix("/", ServeSPA(os.DirFS("testdata/spa"), "index.html", tt.opts...))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/about", nil)
+			r.Header.Set("Sec-Fetch-Mode", "cors")
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("expected status to be %d but got %d", http.StatusNotFound, w.Code)
+			}
+			vary := strings.Join(w.Header().Values("Vary"), ", ")
+			for _, field := range []string{"Accept", "Sec-Fetch-Mode"} {
+				if !slices.Contains(strings.Split(vary, ", "), field) {
+					t.Errorf("expected Vary to include %q but got %q", field, vary)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package fileserver
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Serves content compressed on-the-fly while it's written to the response.
+//
+// Since the compressed length isn't known upfront, the response is sent without a Content-Length
+// (using chunked transfer encoding on HTTP/1.1) and Range requests aren't supported. Conditional
+// requests are still evaluated, see [checkPreconditions].
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.Reader, encoding string) {
+	if !isZeroTime(modtime) {
+		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Accept-Ranges", "none")
+
+	if status := checkPreconditions(r, w.Header().Get("ETag"), modtime); status != 0 {
+		if status == http.StatusNotModified {
+			w.Header().Del("Content-Type")
+			w.Header().Set("Content-Encoding", encoding)
+			w.WriteHeader(status)
+			return
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Encoding", encoding)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	out := &abortableWriter{w: w}
+	enc, err := s.encoders[encoding](out)
+	if err != nil {
+		s.errHandler(w, r, &Error{StatusCode: http.StatusInternalServerError, Op: "compress", Path: name, Err: err})
+		return
+	}
+
+	w.Header().Set("Content-Encoding", encoding)
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent at this point, so errors can only abort the response. The encoder is
+	// still closed to release its state, but its trailer is discarded, so the client doesn't receive a
+	// truncated stream that decodes without errors.
+	if _, err := io.Copy(enc, content); err != nil {
+		out.abort()
+	}
+	_ = enc.Close()
+}
+
+// abortableWriter writes to w until it's aborted, after which writes are discarded.
+type abortableWriter struct {
+	w       io.Writer
+	aborted bool
+}
+
+func (a *abortableWriter) Write(p []byte) (int, error) {
+	if a.aborted {
+		return len(p), nil
+	}
+	return a.w.Write(p)
+}
+
+func (a *abortableWriter) abort() {
+	a.aborted = true
+}
+
+// Evaluates the conditional request headers of r as described by RFC 9110, section 13.2.2, for
+// responses that aren't served by [http.ServeContent]. It returns the status code the server must
+// respond with, either 304 Not Modified or 412 Precondition Failed, or 0 if the request should
+// proceed.
+func checkPreconditions(r *http.Request, etag string, modtime time.Time) int {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ifUnmodifiedSince := r.Header.Get("If-Unmodified-Since"); ifUnmodifiedSince != "" && !isZeroTime(modtime) {
+		if t, err := http.ParseTime(ifUnmodifiedSince); err == nil && modtime.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, true) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !isZeroTime(modtime) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return 0
+		}
+		if t, err := http.ParseTime(ifModifiedSince); err == nil && !modtime.Truncate(time.Second).After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// Reports whether any of the comma-separated ETags of header matches etag. The '*' value matches
+// any existing ETag. Weak comparison ignores the 'W/' prefix, while strong comparison never matches
+// weak ETags.
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if etag == "" {
+			continue
+		}
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(tag, "W/") && tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether t is the zero time or the Unix epoch, which [fs.FS] implementations
+// such as [embed.FS] report for files without a modification time.
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(time.Unix(0, 0))
+}
@@ -0,0 +1,181 @@
+package fileserver
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	modtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	etag := `"abc-gzip"`
+
+	testCases := []struct {
+		name     string
+		method   string
+		header   map[string]string
+		expected int
+	}{
+		{
+			name:     "no conditions",
+			method:   http.MethodGet,
+			expected: 0,
+		},
+		{
+			name:     "if-none-match (match)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-None-Match": `"xyz", W/"abc-gzip"`},
+			expected: http.StatusNotModified,
+		},
+		{
+			name:     "if-none-match (no match)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-None-Match": `"xyz"`},
+			expected: 0,
+		},
+		{
+			name:     "if-none-match takes precedence over if-modified-since",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": modtime.Format(http.TimeFormat)},
+			expected: 0,
+		},
+		{
+			name:     "if-modified-since (not modified)",
+			method:   http.MethodHead,
+			header:   map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat)},
+			expected: http.StatusNotModified,
+		},
+		{
+			name:     "if-modified-since (modified)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-Modified-Since": modtime.Add(-time.Hour).Format(http.TimeFormat)},
+			expected: 0,
+		},
+		{
+			name:     "if-match (match)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-Match": etag},
+			expected: 0,
+		},
+		{
+			name:     "if-match (weak never matches)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-Match": "W/" + etag},
+			expected: http.StatusPreconditionFailed,
+		},
+		{
+			name:     "if-match (wildcard)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-Match": "*"},
+			expected: 0,
+		},
+		{
+			name:     "if-unmodified-since (modified)",
+			method:   http.MethodGet,
+			header:   map[string]string{"If-Unmodified-Since": modtime.Add(-time.Hour).Format(http.TimeFormat)},
+			expected: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for key, value := range tt.header {
+				r.Header.Set(key, value)
+			}
+
+			status := checkPreconditions(r, etag, modtime)
+			if status != tt.expected {
+				t.Errorf("expected status to be %d but got %d", tt.expected, status)
+			}
+		})
+	}
+}
+
+// trackedEncoder records whether gzip encoders it creates are closed.
+type trackedEncoder struct {
+	created, closed int
+}
+
+func (e *trackedEncoder) encode(w io.Writer) (io.WriteCloser, error) {
+	e.created++
+	return &trackedWriteCloser{Writer: gzip.NewWriter(w), closed: &e.closed}, nil
+}
+
+type trackedWriteCloser struct {
+	*gzip.Writer
+	closed *int
+}
+
+func (w *trackedWriteCloser) Close() error {
+	*w.closed++
+	return w.Writer.Close()
+}
+
+// failingReader fails after its data has been read.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestServeStreamClosesEncoder(t *testing.T) {
+	content := strings.Repeat("console.log('hello, world');\n", 100)
+
+	testCases := []struct {
+		name    string
+		method  string
+		content io.Reader
+		created int
+	}{
+		{name: "get", method: http.MethodGet, content: strings.NewReader(content), created: 1},
+		{name: "head", method: http.MethodHead, content: strings.NewReader(content), created: 0},
+		{name: "failed copy", method: http.MethodGet, content: &failingReader{data: []byte(content)}, created: 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := &trackedEncoder{}
+			s := New(fstest.MapFS{}, WithEncoder("gzip", enc.encode))
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/app.js", nil)
+			s.serveStream(w, r, "app.js", time.Time{}, tt.content, "gzip")
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status to be %d but got %d", http.StatusOK, w.Code)
+			}
+			if enc.created != tt.created || enc.closed != tt.created {
+				t.Errorf("expected %d encoders to be created and closed but got %d and %d", tt.created, enc.created, enc.closed)
+			}
+		})
+	}
+}
+
+func TestServeStreamAbortDiscardsTrailer(t *testing.T) {
+	content := strings.Repeat("console.log('hello, world');\n", 100)
+	s := New(fstest.MapFS{})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	s.serveStream(w, r, "app.js", time.Time{}, &failingReader{data: []byte(content)}, "gzip")
+
+	// The body may be empty, since the encoder buffers its output.
+	if zr, err := gzip.NewReader(w.Body); err == nil {
+		if _, err := io.ReadAll(zr); err == nil {
+			t.Error("expected an aborted stream not to decode without errors")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package fileserver
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// SurrogateKeysFunc returns the surrogate keys of a file, also known as cache tags, which CDNs use to
+// purge groups of cached responses at once. name is the slash-separated path of the served file.
+// See [WithSurrogateKeys].
+type SurrogateKeysFunc func(name string) []string
+
+// Creates a [SurrogateKeysFunc] that tags files with:
+//
+//   - Their path, such as 'file:assets/app.js';
+//   - Their dir, such as 'dir:assets/css', or 'dir:/' for the root;
+//   - Their extension, such as 'ext:js';
+//   - The deploy ID, such as 'deploy:v1.2.0', if not empty.
+func DefaultSurrogateKeys(deployID string) SurrogateKeysFunc {
+	return func(name string) []string {
+		name = strings.TrimPrefix(name, "/")
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = "/"
+		}
+		keys := []string{"file:" + name, "dir:" + dir}
+		if ext := path.Ext(name); ext != "" {
+			keys = append(keys, "ext:"+strings.ToLower(ext[1:]))
+		}
+		if deployID != "" {
+			keys = append(keys, "deploy:"+deployID)
+		}
+		return keys
+	}
+}
+
+// Sets the 'Surrogate-Key' (space-separated) and 'Cache-Tag' (comma-separated) headers to keys. Keys containing spaces or commas are skipped, since they can't be
+// represented in either header.
+func setSurrogateKeys(w http.ResponseWriter, keys []string) {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != "" && !strings.ContainsAny(key, " ,\t") && !slices.Contains(valid, key) {
+			valid = append(valid, key)
+		}
+	}
+	if len(valid) == 0 {
+		return
+	}
+	w.Header().Set("Surrogate-Key", strings.Join(valid, " "))
+	w.Header().Set("Cache-Tag", strings.Join(valid, ","))
+}
+
+// Returns the sorted surrogate keys affected by the changes between two snapshots of a dir, such as
+// the manifests of the previous and the current deploys, so only those keys need to be purged from
+// a CDN. A key is affected if any file tagged with it was added, removed or modified.
+//
+// Keys that differ between every deploy, such as the deploy ID, would purge everything, so keysFn
+// shouldn't include them. For example, pass [DefaultSurrogateKeys] with an empty deploy ID.
+//
+// A nil manifest is treated as an empty one, so with no previous deploy every current key is affected.
+func ChangedSurrogateKeys(previous, current *Manifest, keysFn SurrogateKeysFunc) []string {
+	if previous == nil {
+		previous = &Manifest{}
+	}
+	if current == nil {
+		current = &Manifest{}
+	}
+	changed := make(map[string]struct{})
+	tag := func(name string) {
+		for _, key := range keysFn(name) {
+			changed[key] = struct{}{}
+		}
+	}
+
+	for name, entry := range current.Files {
+		if prev, ok := previous.Files[name]; !ok || !entry.sameContent(prev) {
+			tag(name)
+		}
+	}
+	for name := range previous.Files {
+		if _, ok := current.Files[name]; !ok {
+			tag(name)
+		}
+	}
+
+	keys := make([]string, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package fileserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestDefaultSurrogateKeys(t *testing.T) {
+	testCases := []struct {
+		name     string
+		deployID string
+		expected []string
+	}{
+		{
+			name:     "file.txt",
+			expected: []string{"file:file.txt", "dir:/", "ext:txt"},
+		},
+		{
+			name:     "assets/css/app.CSS",
+			deployID: "v1.2.0",
+			expected: []string{"file:assets/css/app.CSS", "dir:assets/css", "ext:css", "deploy:v1.2.0"},
+		},
+		{
+			name:     "/LICENSE",
+			expected: []string{"file:LICENSE", "dir:/"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := DefaultSurrogateKeys(tt.deployID)(tt.name)
+			if !slices.Equal(keys, tt.expected) {
+				t.Errorf("expected keys to be %v but got %v", tt.expected, keys)
+			}
+		})
+	}
+}
+
+func TestWithSurrogateKeys(t *testing.T) {
+	h := New(os.DirFS("testdata"), WithSurrogateKeys(func(name string) []string {
+		return []string{"file:" + name, "has space", "has,comma", "file:" + name}
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/subdir/subfile.txt", nil)
+	http.StripPrefix("/", h).ServeHTTP(w, r)
+
+	if key := w.Header().Get("Surrogate-Key"); key != "file:subdir/subfile.txt" {
+		t.Errorf("expected Surrogate-Key to be %q but got %q", "file:subdir/subfile.txt", key)
+	}
+	if tag := w.Header().Get("Cache-Tag"); tag != "file:subdir/subfile.txt" {
+		t.Errorf("expected Cache-Tag to be %q but got %q", "file:subdir/subfile.txt", tag)
+	}
+
+	t.Run("multiple keys", func(t *testing.T) {
+		h := New(os.DirFS("testdata"), WithSurrogateKeys(DefaultSurrogateKeys("42")))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		http.StripPrefix("/", h).ServeHTTP(w, r)
+
+		if key := w.Header().Get("Surrogate-Key"); key != "file:file.txt dir:/ ext:txt deploy:42" {
+			t.Errorf("expected Surrogate-Key to be %q but got %q", "file:file.txt dir:/ ext:txt deploy:42", key)
+		}
+		if tag := w.Header().Get("Cache-Tag"); tag != "file:file.txt,dir:/,ext:txt,deploy:42" {
+			t.Errorf("expected Cache-Tag to be %q but got %q", "file:file.txt,dir:/,ext:txt,deploy:42", tag)
+		}
+	})
+}
+
+func TestChangedSurrogateKeys(t *testing.T) {
+	previous := &Manifest{Files: map[string]ManifestEntry{
+		"index.html":       {ETag: `"a"`, Size: 10},
+		"assets/app.js":    {ETag: `"b"`, Size: 20},
+		"assets/old.css":   {ETag: `"c"`, Size: 30},
+		"images/logo.png":  {ETag: `"d"`, Size: 40},
+		"images/other.png": {ETag: `"e"`, Size: 50},
+	}}
+	current := &Manifest{Files: map[string]ManifestEntry{
+		"index.html":       {ETag: `"a"`, Size: 10},
+		"assets/app.js":    {ETag: `"f"`, Size: 20},
+		"images/logo.png":  {ETag: `"d"`, Size: 40},
+		"images/other.png": {ETag: `"e"`, Size: 50},
+		"fonts/inter.woff": {ETag: `"g"`, Size: 60},
+	}}
+
+	expected := []string{
+		"dir:assets",
+		"dir:fonts",
+		"ext:css",
+		"ext:js",
+		"ext:woff",
+		"file:assets/app.js",
+		"file:assets/old.css",
+		"file:fonts/inter.woff",
+	}
+	keys := ChangedSurrogateKeys(previous, current, DefaultSurrogateKeys(""))
+	if !slices.Equal(keys, expected) {
+		t.Errorf("expected keys to be %v but got %v", expected, keys)
+	}
+
+	if keys := ChangedSurrogateKeys(current, current, DefaultSurrogateKeys("")); len(keys) != 0 {
+		t.Errorf("expected no keys but got %v", keys)
+	}
+
+	expected = []string{
+		"dir:/",
+		"dir:assets",
+		"dir:fonts",
+		"dir:images",
+		"ext:html",
+		"ext:js",
+		"ext:png",
+		"ext:woff",
+		"file:assets/app.js",
+		"file:fonts/inter.woff",
+		"file:images/logo.png",
+		"file:images/other.png",
+		"file:index.html",
+	}
+	if keys := ChangedSurrogateKeys(nil, current, DefaultSurrogateKeys("")); !slices.Equal(keys, expected) {
+		t.Errorf("expected keys without a previous manifest to be %v but got %v", expected, keys)
+	}
+}
@@ -0,0 +1,180 @@
+export function greet0(name) {
+  return `Hello, ${name}! (0)`;
+}
+export function greet1(name) {
+  return `Hello, ${name}! (1)`;
+}
+export function greet2(name) {
+  return `Hello, ${name}! (2)`;
+}
+export function greet3(name) {
+  return `Hello, ${name}! (3)`;
+}
+export function greet4(name) {
+  return `Hello, ${name}! (4)`;
+}
+export function greet5(name) {
+  return `Hello, ${name}! (5)`;
+}
+export function greet6(name) {
+  return `Hello, ${name}! (6)`;
+}
+export function greet7(name) {
+  return `Hello, ${name}! (7)`;
+}
+export function greet8(name) {
+  return `Hello, ${name}! (8)`;
+}
+export function greet9(name) {
+  return `Hello, ${name}! (9)`;
+}
+export function greet10(name) {
+  return `Hello, ${name}! (10)`;
+}
+export function greet11(name) {
+  return `Hello, ${name}! (11)`;
+}
+export function greet12(name) {
+  return `Hello, ${name}! (12)`;
+}
+export function greet13(name) {
+  return `Hello, ${name}! (13)`;
+}
+export function greet14(name) {
+  return `Hello, ${name}! (14)`;
+}
+export function greet15(name) {
+  return `Hello, ${name}! (15)`;
+}
+export function greet16(name) {
+  return `Hello, ${name}! (16)`;
+}
+export function greet17(name) {
+  return `Hello, ${name}! (17)`;
+}
+export function greet18(name) {
+  return `Hello, ${name}! (18)`;
+}
+export function greet19(name) {
+  return `Hello, ${name}! (19)`;
+}
+export function greet20(name) {
+  return `Hello, ${name}! (20)`;
+}
+export function greet21(name) {
+  return `Hello, ${name}! (21)`;
+}
+export function greet22(name) {
+  return `Hello, ${name}! (22)`;
+}
+export function greet23(name) {
+  return `Hello, ${name}! (23)`;
+}
+export function greet24(name) {
+  return `Hello, ${name}! (24)`;
+}
+export function greet25(name) {
+  return `Hello, ${name}! (25)`;
+}
+export function greet26(name) {
+  return `Hello, ${name}! (26)`;
+}
+export function greet27(name) {
+  return `Hello, ${name}! (27)`;
+}
+export function greet28(name) {
+  return `Hello, ${name}! (28)`;
+}
+export function greet29(name) {
+  return `Hello, ${name}! (29)`;
+}
+export function greet30(name) {
+  return `Hello, ${name}! (30)`;
+}
+export function greet31(name) {
+  return `Hello, ${name}! (31)`;
+}
+export function greet32(name) {
+  return `Hello, ${name}! (32)`;
+}
+export function greet33(name) {
+  return `Hello, ${name}! (33)`;
+}
+export function greet34(name) {
+  return `Hello, ${name}! (34)`;
+}
+export function greet35(name) {
+  return `Hello, ${name}! (35)`;
+}
+export function greet36(name) {
+  return `Hello, ${name}! (36)`;
+}
+export function greet37(name) {
+  return `Hello, ${name}! (37)`;
+}
+export function greet38(name) {
+  return `Hello, ${name}! (38)`;
+}
+export function greet39(name) {
+  return `Hello, ${name}! (39)`;
+}
+export function greet40(name) {
+  return `Hello, ${name}! (40)`;
+}
+export function greet41(name) {
+  return `Hello, ${name}! (41)`;
+}
+export function greet42(name) {
+  return `Hello, ${name}! (42)`;
+}
+export function greet43(name) {
+  return `Hello, ${name}! (43)`;
+}
+export function greet44(name) {
+  return `Hello, ${name}! (44)`;
+}
+export function greet45(name) {
+  return `Hello, ${name}! (45)`;
+}
+export function greet46(name) {
+  return `Hello, ${name}! (46)`;
+}
+export function greet47(name) {
+  return `Hello, ${name}! (47)`;
+}
+export function greet48(name) {
+  return `Hello, ${name}! (48)`;
+}
+export function greet49(name) {
+  return `Hello, ${name}! (49)`;
+}
+export function greet50(name) {
+  return `Hello, ${name}! (50)`;
+}
+export function greet51(name) {
+  return `Hello, ${name}! (51)`;
+}
+export function greet52(name) {
+  return `Hello, ${name}! (52)`;
+}
+export function greet53(name) {
+  return `Hello, ${name}! (53)`;
+}
+export function greet54(name) {
+  return `Hello, ${name}! (54)`;
+}
+export function greet55(name) {
+  return `Hello, ${name}! (55)`;
+}
+export function greet56(name) {
+  return `Hello, ${name}! (56)`;
+}
+export function greet57(name) {
+  return `Hello, ${name}! (57)`;
+}
+export function greet58(name) {
+  return `Hello, ${name}! (58)`;
+}
+export function greet59(name) {
+  return `Hello, ${name}! (59)`;
+}
@@ -0,0 +1,165 @@
+export function greet0(name) {
+  return `Hi, ${name}! (0)`;
+}
+export function greet1(name) {
+  return `Hi, ${name}! (1)`;
+}
+export function greet2(name) {
+  return `Hi, ${name}! (2)`;
+}
+export function greet3(name) {
+  return `Hi, ${name}! (3)`;
+}
+export function greet4(name) {
+  return `Hi, ${name}! (4)`;
+}
+export function greet5(name) {
+  return `Hi, ${name}! (5)`;
+}
+export function greet6(name) {
+  return `Hi, ${name}! (6)`;
+}
+export function greet7(name) {
+  return `Hi, ${name}! (7)`;
+}
+export function greet8(name) {
+  return `Hi, ${name}! (8)`;
+}
+export function greet9(name) {
+  return `Hi, ${name}! (9)`;
+}
+export function greet10(name) {
+  return `Hi, ${name}! (10)`;
+}
+export function greet11(name) {
+  return `Hi, ${name}! (11)`;
+}
+export function greet12(name) {
+  return `Hi, ${name}! (12)`;
+}
+export function greet13(name) {
+  return `Hi, ${name}! (13)`;
+}
+export function greet14(name) {
+  return `Hi, ${name}! (14)`;
+}
+export function greet15(name) {
+  return `Hi, ${name}! (15)`;
+}
+export function greet16(name) {
+  return `Hi, ${name}! (16)`;
+}
+export function greet17(name) {
+  return `Hi, ${name}! (17)`;
+}
+export function greet18(name) {
+  return `Hi, ${name}! (18)`;
+}
+export function greet19(name) {
+  return `Hi, ${name}! (19)`;
+}
+export function greet20(name) {
+  return `Hi, ${name}! (20)`;
+}
+export function greet21(name) {
+  return `Hi, ${name}! (21)`;
+}
+export function greet22(name) {
+  return `Hi, ${name}! (22)`;
+}
+export function greet23(name) {
+  return `Hi, ${name}! (23)`;
+}
+export function greet24(name) {
+  return `Hi, ${name}! (24)`;
+}
+export function greet25(name) {
+  return `Hi, ${name}! (25)`;
+}
+export function greet26(name) {
+  return `Hi, ${name}! (26)`;
+}
+export function greet27(name) {
+  return `Hi, ${name}! (27)`;
+}
+export function greet28(name) {
+  return `Hi, ${name}! (28)`;
+}
+export function greet29(name) {
+  return `Hi, ${name}! (29)`;
+}
+export function greet30(name) {
+  return `Hi, ${name}! (30)`;
+}
+export function greet31(name) {
+  return `Hi, ${name}! (31)`;
+}
+export function greet32(name) {
+  return `Hi, ${name}! (32)`;
+}
+export function greet33(name) {
+  return `Hi, ${name}! (33)`;
+}
+export function greet34(name) {
+  return `Hi, ${name}! (34)`;
+}
+export function greet35(name) {
+  return `Hi, ${name}! (35)`;
+}
+export function greet36(name) {
+  return `Hi, ${name}! (36)`;
+}
+export function greet37(name) {
+  return `Hi, ${name}! (37)`;
+}
+export function greet38(name) {
+  return `Hi, ${name}! (38)`;
+}
+export function greet39(name) {
+  return `Hi, ${name}! (39)`;
+}
+export function greet40(name) {
+  return `Hi, ${name}! (40)`;
+}
+export function greet41(name) {
+  return `Hi, ${name}! (41)`;
+}
+export function greet42(name) {
+  return `Hi, ${name}! (42)`;
+}
+export function greet43(name) {
+  return `Hi, ${name}! (43)`;
+}
+export function greet44(name) {
+  return `Hi, ${name}! (44)`;
+}
+export function greet45(name) {
+  return `Hi, ${name}! (45)`;
+}
+export function greet46(name) {
+  return `Hi, ${name}! (46)`;
+}
+export function greet47(name) {
+  return `Hi, ${name}! (47)`;
+}
+export function greet48(name) {
+  return `Hi, ${name}! (48)`;
+}
+export function greet49(name) {
+  return `Hi, ${name}! (49)`;
+}
+export function greet50(name) {
+  return `Hi, ${name}! (50)`;
+}
+export function greet51(name) {
+  return `Hi, ${name}! (51)`;
+}
+export function greet52(name) {
+  return `Hi, ${name}! (52)`;
+}
+export function greet53(name) {
+  return `Hi, ${name}! (53)`;
+}
+export function greet54(name) {
+  return `Hi, ${name}! (54)`;
+}